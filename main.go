@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"time"
 
 	"main/terrain"
@@ -16,12 +17,25 @@ func main() {
 		MapHeight  = 256
 		MapOctaves = 12
 		MapSeed    = 3421
+		SeaLevel   = 0.0 // Nivel del mar en el rango [-1, 1] del mapa (la playa del TerrainColorMap)
 	)
 
+	// Binary PLY by default; terrain.PLYASCII writes a readable (and much larger) file
+	const PLYFormat = terrain.PLYBinary
+
+	// Adaptive RTIN mesh instead of two triangles per cell, within this vertical error (m);
+	// also used for the final mesh and its LODs
+	const (
+		UseAdaptiveMesh   = false
+		AdaptiveMeshError = 0.5
+	)
+	meshOptions := terrain.MeshOptions{Adaptive: UseAdaptiveMesh, MaxError: AdaptiveMeshError}
+
 	// Create output directory if not exists
 	const (
 		imageDir = "images"
 		meshDir  = "meshes"
+		dataDir  = "data"
 	)
 
 	ErosionParams := terrain.ErosionParams{
@@ -34,11 +48,39 @@ func main() {
 		Gravity:          9.8,
 		MinSlope:         0.01,
 		CellSize:         1.0,
+		Workers:          runtime.NumCPU(),
+		Radius:           3,
 	}
 	ErosionDropletCount := 200000
 
+	// Alternative grid-based erosion (virtual pipes); replaces the droplets when enabled
+	const UsePipeErosion = false
+	PipeErosionParams := terrain.PipeErosionParams{
+		Iterations:       300,
+		TimeStep:         0.02,
+		RainRate:         0.01,
+		EvaporationRate:  0.05,
+		PipeArea:         1.0,
+		Gravity:          9.8,
+		SedimentCapacity: 0.05,
+		DissolvingRate:   0.3,
+		DepositionRate:   0.3,
+		MinTilt:          0.05,
+		CellSize:         2.0 / MapHeight,
+	}
+
+	// Heights stay in [-1, 1], so one cell (1 m) measures 2/MapHeight in height units
+	ThermalParams := terrain.ThermalParams{
+		Iterations:   20,
+		TalusAngle:   35,
+		TransferRate: 0.5,
+		Neighbours:   8,
+		CellSize:     2.0 / MapHeight,
+	}
+
 	os.MkdirAll(imageDir, 0755)
 	os.MkdirAll(meshDir, 0755)
+	os.MkdirAll(dataDir, 0755)
 	start_time := time.Now()
 	fmt.Printf("\nInicio de generación: %s\n", time.Now().Format("15:04:05"))
 
@@ -53,7 +95,7 @@ func main() {
 	}
 
 	noiseStart := time.Now()
-	heightmap := terrain.CreateNoiseMap(MapSeed, MapSize, MapScale, MapOctaves, Smoother)
+	heightmap := terrain.CreateNoiseMap(terrain.NewOpenSimplex(MapSeed), MapSize, MapScale, MapOctaves, Smoother)
 	fmt.Printf("\nGeneración de mapa de ruido: %.3f segundos\n", time.Since(noiseStart).Seconds())
 
 	const num = 10
@@ -64,36 +106,164 @@ func main() {
 		// Aplicar erosión con el nuevo enfoque (solo necesita el número de gotas)
 		scaleStart := time.Now()
 		// Create a scaled copy of heightmap from [-1,1] to [0,256]
-		scaledHeightmap := make([][]float64, len(heightmap))
-		for y := range heightmap {
-			scaledHeightmap[y] = make([]float64, len(heightmap[y]))
-			for x := range heightmap[y] {
-				scaledHeightmap[y][x] = (heightmap[y][x] + 1) * MapHeight / 2
-			}
-		}
+		scaledHeightmap := heightmap.Remap(0, MapHeight, "m")
 		fmt.Printf("\nEscalado del mapa: %.3f segundos\n", time.Since(scaleStart).Seconds())
 
 		imgPath := filepath.Join(imageDir, fmt.Sprintf("terrain_render_%d.png", i))
 		meshPath := filepath.Join(meshDir, fmt.Sprintf("eroded_terrain_%d.ply", i))
 
-		meshStart := time.Now()
-		vertices, faces, colors := terrain.GenerateHeightmapMesh(scaledHeightmap)
-		fmt.Printf("\nGeneración de malla: %.3f segundos\n", time.Since(meshStart).Seconds())
-
 		plyStart := time.Now()
-		terrain.SavePLY(meshPath, vertices, faces, colors)
-		fmt.Printf("\nGuardado del archivo PLY: %.3f segundos\n", time.Since(plyStart).Seconds())
+		var err error
+		if UseAdaptiveMesh {
+			vertices, faces, colors := terrain.GenerateHeightmapMeshWithOptions(scaledHeightmap, meshOptions)
+			err = terrain.SavePLYFormat(meshPath, vertices, faces, colors, PLYFormat)
+		} else {
+			err = terrain.SaveHeightmapPLY(meshPath, scaledHeightmap, PLYFormat)
+		}
+		if err != nil {
+			fmt.Printf("Error guardando malla PLY: %v\n", err)
+		}
+		fmt.Printf("\nGeneración y guardado del archivo PLY: %.3f segundos\n", time.Since(plyStart).Seconds())
 
 		renderStart := time.Now()
 		terrain.RenderTerrainIsometric(meshPath, imgPath)
 		fmt.Printf("\nRenderizado: %.3f segundos\n", time.Since(renderStart).Seconds())
 
 		erosionStart := time.Now()
-		heightmap = terrain.ApplyErosion(heightmap, ErosionDropletCount, ErosionParams)
-		fmt.Printf("\nAplicación de erosión (%d gotas): %.3f segundos\n", ErosionDropletCount, time.Since(erosionStart).Seconds())
+		ErosionParams.Seed = MapSeed + int64(i)
+		var eroder terrain.Eroder = terrain.DropletErosion{Droplets: ErosionDropletCount, Params: ErosionParams}
+		if UsePipeErosion {
+			eroder = PipeErosionParams
+		}
+		heightmap = eroder.Erode(heightmap)
+		fmt.Printf("\nAplicación de erosión hidráulica: %.3f segundos\n", time.Since(erosionStart).Seconds())
+
+		thermalStart := time.Now()
+		heightmap = terrain.ApplyThermalErosion(heightmap, ThermalParams)
+		fmt.Printf("\nAplicación de erosión térmica: %.3f segundos\n", time.Since(thermalStart).Seconds())
 
 		fmt.Printf("\nTiempo total de iteración %d: %.3f segundos\n", i+1, time.Since(iterStart).Seconds())
 	}
 
+	// Heightmap final para motores y editores de terreno (Unity, Unreal, Gaea, World Machine)
+	exportStart := time.Now()
+	heightRange := terrain.HeightRange{Min: -1, Max: 1}
+	if err := terrain.SaveHeightmapPNG16(filepath.Join(dataDir, "heightmap.png"), heightmap, heightRange); err != nil {
+		fmt.Printf("Error guardando heightmap PNG: %v\n", err)
+	}
+	if err := terrain.SaveHeightmapR16(filepath.Join(dataDir, "heightmap.r16"), heightmap, heightRange); err != nil {
+		fmt.Printf("Error guardando heightmap RAW: %v\n", err)
+	}
+	if err := terrain.SaveHeightmapTIFF(filepath.Join(dataDir, "heightmap.tif"), heightmap.Remap(0, MapHeight, "m"), terrain.HeightRange{}); err != nil {
+		fmt.Printf("Error guardando heightmap TIFF: %v\n", err)
+	}
+	fmt.Printf("\nExportación del heightmap: %.3f segundos\n", time.Since(exportStart).Seconds())
+
+	// Malla final para herramientas DCC y motores: OBJ, STL y GLB con la textura horneada
+	meshExportStart := time.Now()
+	finalHeightmap := heightmap.Remap(0, MapHeight, "m")
+	vertices, faces, colors := terrain.GenerateHeightmapMeshWithOptions(finalHeightmap, meshOptions)
+	if err := terrain.SaveOBJ(filepath.Join(meshDir, "terrain_final.obj"), vertices, faces, colors); err != nil {
+		fmt.Printf("Error guardando malla OBJ: %v\n", err)
+	}
+	if err := terrain.SaveSTL(filepath.Join(meshDir, "terrain_final.stl"), vertices, faces); err != nil {
+		fmt.Printf("Error guardando malla STL: %v\n", err)
+	}
+	gltfOptions := terrain.GLTFOptions{Texture: terrain.BakeColorTexture(finalHeightmap)}
+	if err := terrain.SaveGLB(filepath.Join(meshDir, "terrain_final.glb"), vertices, faces, colors, gltfOptions); err != nil {
+		fmt.Printf("Error guardando malla GLB: %v\n", err)
+	}
+
+	// Niveles de detalle simplificados (con el borde intacto para que las teselas encajen),
+	// o extraídos directamente del heightmap con la malla adaptativa
+	LODLevels := []terrain.DecimateParams{
+		{MaxError: 0.05},
+		{TargetTriangles: 200000},
+		{TargetTriangles: 50000},
+	}
+	AdaptiveLODErrors := []float64{2, 8, 32} // Error vertical máximo de cada LOD adaptativo (m)
+	var lods []terrain.MeshLOD
+	if UseAdaptiveMesh {
+		lods = terrain.GenerateAdaptiveLODs(finalHeightmap, AdaptiveLODErrors)
+	} else {
+		lods = terrain.GenerateLODs(vertices, faces, colors, LODLevels)
+	}
+	for k, lod := range lods {
+		lodPath := filepath.Join(meshDir, fmt.Sprintf("terrain_final_lod%d.glb", k+1))
+		if err := terrain.SaveGLB(lodPath, lod.Vertices, lod.Faces, lod.Colors, gltfOptions); err != nil {
+			fmt.Printf("Error guardando LOD %d: %v\n", k+1, err)
+		}
+	}
+	fmt.Printf("\nExportación de la malla final: %.3f segundos\n", time.Since(meshExportStart).Seconds())
+
+	// Lagos y red fluvial del terreno erosionado final
+	riversStart := time.Now()
+	const RiverThreshold = 500 // Celdas de drenaje necesarias para formar un río
+	drained, lakes := terrain.FillDepressions(heightmap, terrain.DepressionBreach, 1e-6)
+	if err := terrain.SaveLakesJSON(filepath.Join(dataDir, "lakes.json"), lakes); err != nil {
+		fmt.Printf("Error guardando lagos: %v\n", err)
+	}
+	if err := terrain.SaveLakeMaskPNG(filepath.Join(imageDir, "lakes_mask.png"), lakes); err != nil {
+		fmt.Printf("Error guardando máscara de lagos: %v\n", err)
+	}
+	flow := terrain.ComputeFlow(drained, terrain.FlowDInfinity)
+	rivers := terrain.ExtractRivers(flow, RiverThreshold)
+	if err := terrain.SaveRiversGeoJSON(filepath.Join(dataDir, "rivers.geojson"), rivers); err != nil {
+		fmt.Printf("Error guardando ríos: %v\n", err)
+	}
+	if err := terrain.SaveRiverMaskPNG(filepath.Join(imageDir, "rivers_mask.png"), rivers); err != nil {
+		fmt.Printf("Error guardando máscara de ríos: %v\n", err)
+	}
+	fmt.Printf("\nExtracción de lagos y ríos: %.3f segundos\n", time.Since(riversStart).Seconds())
+
+	// Costa al nivel del mar configurado
+	coastStart := time.Now()
+	coast := terrain.ExtractCoast(heightmap, SeaLevel)
+	if err := terrain.SaveCoastlinesGeoJSON(filepath.Join(dataDir, "coastlines.geojson"), coast); err != nil {
+		fmt.Printf("Error guardando costas: %v\n", err)
+	}
+	if err := terrain.SaveCoastlinesSVG(filepath.Join(imageDir, "coastlines.svg"), coast); err != nil {
+		fmt.Printf("Error guardando costas SVG: %v\n", err)
+	}
+	if err := terrain.SaveLandMaskPNG(filepath.Join(imageDir, "land_mask.png"), coast); err != nil {
+		fmt.Printf("Error guardando máscara de tierra: %v\n", err)
+	}
+	fmt.Printf("\nExtracción de costa: %.3f segundos\n", time.Since(coastStart).Seconds())
+
+	// Clima y biomas sobre las alturas en metros; lagos y ríos también aportan humedad
+	biomeStart := time.Now()
+	riverMask := rivers.Mask()
+	freshWater := make([]bool, len(lakes.Labels))
+	for i, label := range lakes.Labels {
+		freshWater[i] = label > 0 || riverMask.Pix[i] > 0
+	}
+	climate := terrain.ComputeClimate(heightmap.Remap(0, MapHeight, "m"), terrain.ClimateParams{
+		SeaLevel:           (SeaLevel + 1) / 2 * MapHeight,
+		EquatorTemperature: 28,
+		PoleTemperature:    -25,
+		LatitudeTop:        55,
+		LatitudeBottom:     35,
+		LapseRate:          0.0065,
+		MoistureRange:      150,
+		FreshWater:         freshWater,
+		Noise:              terrain.NewOpenSimplex(MapSeed + 1),
+		NoiseScale:         256,
+		NoiseAmount:        0.15,
+		WindX:              1,
+		RainShadowDistance: 64,
+		RainShadowStrength: 0.7,
+	})
+	if biomes, err := terrain.ClassifyBiomes(climate, terrain.DefaultBiomeTable()); err != nil {
+		fmt.Printf("Error clasificando biomas: %v\n", err)
+	} else {
+		if err := terrain.SaveBiomePNG(filepath.Join(imageDir, "biomes.png"), biomes); err != nil {
+			fmt.Printf("Error guardando biomas: %v\n", err)
+		}
+		if err := terrain.SaveBiomeLegendJSON(filepath.Join(dataDir, "biomes_legend.json"), biomes); err != nil {
+			fmt.Printf("Error guardando leyenda de biomas: %v\n", err)
+		}
+	}
+	fmt.Printf("\nClima y biomas: %.3f segundos\n", time.Since(biomeStart).Seconds())
+
 	fmt.Printf("\nTiempo total de ejecución: %.3f segundos (%.2f minutos)\n", time.Since(start_time).Seconds(), time.Since(start_time).Minutes())
 }
@@ -0,0 +1,179 @@
+package terrain
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"time"
+)
+
+// Biome es una fila de la tabla de clasificación: se elige el primer bioma cuyos rangos
+// contienen la temperatura y la humedad de la celda (y cuyo Water coincide con la celda).
+// Los rangos son semiabiertos, [Min, Max).
+type Biome struct {
+	Name           string
+	Color          color.RGBA
+	Water          bool // Se aplica a celdas de agua en lugar de tierra
+	MinTemperature float64
+	MaxTemperature float64
+	MinMoisture    float64
+	MaxMoisture    float64
+}
+
+// BiomeMap contiene el bioma de cada celda como posición en Table
+type BiomeMap struct {
+	Width  int
+	Height int
+	Table  []Biome
+	Index  []uint8 // Posición en Table de cada celda (y*Width + x)
+}
+
+// DefaultBiomeTable devuelve una clasificación simplificada de Whittaker
+// (temperatura en °C, humedad de 0 a 1)
+func DefaultBiomeTable() []Biome {
+	inf := math.Inf(1)
+	return []Biome{
+		{"Hielo marino", color.RGBA{0xDD, 0xEE, 0xFF, 0xFF}, true, -inf, -2, -inf, inf},
+		{"Océano", color.RGBA{0x00, 0x77, 0xBE, 0xFF}, true, -inf, inf, -inf, inf},
+		{"Glaciar", color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}, false, -inf, -10, -inf, inf},
+		{"Tundra", color.RGBA{0x9E, 0xA8, 0x8C, 0xFF}, false, -10, 0, -inf, inf},
+		{"Desierto frío", color.RGBA{0xC9, 0xB9, 0x8B, 0xFF}, false, 0, 10, -inf, 0.25},
+		{"Taiga", color.RGBA{0x3B, 0x5E, 0x3B, 0xFF}, false, 0, 10, 0.25, inf},
+		{"Pradera", color.RGBA{0xA5, 0xC2, 0x5F, 0xFF}, false, 10, 20, -inf, 0.33},
+		{"Bosque templado", color.RGBA{0x22, 0x8B, 0x22, 0xFF}, false, 10, 20, 0.33, 0.66},
+		{"Selva templada", color.RGBA{0x1E, 0x64, 0x3C, 0xFF}, false, 10, 20, 0.66, inf},
+		{"Desierto", color.RGBA{0xE8, 0xD0, 0x8C, 0xFF}, false, 20, inf, -inf, 0.25},
+		{"Sabana", color.RGBA{0xC2, 0xB2, 0x4C, 0xFF}, false, 20, inf, 0.25, 0.6},
+		{"Selva tropical", color.RGBA{0x0B, 0x6E, 0x1E, 0xFF}, false, 20, inf, 0.6, inf},
+	}
+}
+
+// ClassifyBiomes asigna a cada celda el primer bioma de table que encaja con su clima. Las
+// celdas sin ninguna fila reciben la primera de su tipo (tierra o agua), o la fila 0.
+func ClassifyBiomes(climate *ClimateMaps, table []Biome) (*BiomeMap, error) {
+	startTotal := time.Now()
+	fmt.Printf("Iniciando clasificación de biomas (%d biomas)...\n", len(table))
+
+	if len(table) == 0 || len(table) > 256 {
+		return nil, fmt.Errorf("la tabla de biomas debe tener entre 1 y 256 entradas, tiene %d", len(table))
+	}
+
+	width := climate.Temperature.Width
+	height := climate.Temperature.Height
+	biomes := &BiomeMap{
+		Width:  width,
+		Height: height,
+		Table:  table,
+		Index:  make([]uint8, width*height),
+	}
+
+	// Primera fila de cada tipo (los tipos sin filas usan la fila 0)
+	fallback := map[bool]int{}
+	for k := len(table) - 1; k >= 0; k-- {
+		fallback[table[k].Water] = k
+	}
+
+	counts := make([]int, len(table))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			t := climate.Temperature.At(x, y)
+			m := climate.Moisture.At(x, y)
+			water := climate.Water[i]
+
+			index := fallback[water]
+			for k, b := range table {
+				if b.Water == water && t >= b.MinTemperature && t < b.MaxTemperature &&
+					m >= b.MinMoisture && m < b.MaxMoisture {
+					index = k
+					break
+				}
+			}
+			biomes.Index[i] = uint8(index)
+			counts[index]++
+		}
+	}
+
+	fmt.Printf("  ├─ Distribución:\n")
+	for k, b := range table {
+		branch := "├"
+		if k == len(table)-1 {
+			branch = "└"
+		}
+		fmt.Printf("  │  %s─ %s: %.1f%%\n", branch, b.Name, float64(counts[k])/float64(max(width*height, 1))*100)
+	}
+	fmt.Printf("  └─ Tiempo total de clasificación: %.3f ms\n", float64(time.Since(startTotal).Microseconds())/1000)
+
+	return biomes, nil
+}
+
+// Image devuelve el mapa de biomas como imagen indexada con la paleta de la tabla
+func (biomes *BiomeMap) Image() *image.Paletted {
+	palette := make(color.Palette, len(biomes.Table))
+	for k, b := range biomes.Table {
+		palette[k] = b.Color
+	}
+	img := image.NewPaletted(image.Rect(0, 0, biomes.Width, biomes.Height), palette)
+	for y := 0; y < biomes.Height; y++ {
+		copy(img.Pix[y*img.Stride:y*img.Stride+biomes.Width], biomes.Index[y*biomes.Width:(y+1)*biomes.Width])
+	}
+	return img
+}
+
+// SaveBiomePNG guarda el mapa de biomas como PNG indexado (un índice de la tabla por píxel)
+func SaveBiomePNG(filename string, biomes *BiomeMap) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return png.Encode(file, biomes.Image())
+}
+
+// SaveBiomeLegendJSON guarda la leyenda del PNG indexado: índice, nombre, color y rangos
+// de cada bioma (los rangos sin límite se escriben como null)
+func SaveBiomeLegendJSON(filename string, biomes *BiomeMap) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	bound := func(v float64) *float64 {
+		if math.IsInf(v, 0) {
+			return nil
+		}
+		return &v
+	}
+	type legendEntry struct {
+		Index          int      `json:"index"`
+		Name           string   `json:"name"`
+		Color          string   `json:"color"`
+		Water          bool     `json:"water"`
+		MinTemperature *float64 `json:"min_temperature"`
+		MaxTemperature *float64 `json:"max_temperature"`
+		MinMoisture    *float64 `json:"min_moisture"`
+		MaxMoisture    *float64 `json:"max_moisture"`
+	}
+	legend := make([]legendEntry, len(biomes.Table))
+	for k, b := range biomes.Table {
+		legend[k] = legendEntry{
+			Index:          k,
+			Name:           b.Name,
+			Color:          fmt.Sprintf("#%02X%02X%02X", b.Color.R, b.Color.G, b.Color.B),
+			Water:          b.Water,
+			MinTemperature: bound(b.MinTemperature),
+			MaxTemperature: bound(b.MaxTemperature),
+			MinMoisture:    bound(b.MinMoisture),
+			MaxMoisture:    bound(b.MaxMoisture),
+		}
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(legend)
+}
@@ -0,0 +1,146 @@
+package terrain
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Parameters for the temperature and moisture maps. Heights use the heightmap's units,
+// so LapseRate and SeaLevel must match them (e.g. metres after Remap).
+type ClimateParams struct {
+	SeaLevel float64 // Cells at or below this height are water
+
+	// Temperature: sea level temperature from latitude, minus the lapse rate with altitude
+	EquatorTemperature float64 // °C at latitude 0
+	PoleTemperature    float64 // °C at latitude ±90
+	LatitudeTop        float64 // Latitude of the first row, in degrees
+	LatitudeBottom     float64 // Latitude of the last row, in degrees
+	LapseRate          float64 // °C lost per height unit above sea level (0.0065 for metres)
+
+	// Moisture: decays with distance to water, perturbed by noise. Only cells at or below
+	// SeaLevel are water unless FreshWater adds lakes and rivers above it.
+	MoistureRange float64 // Distance (in CellSize units) at which moisture from water drops to 1/e
+	FreshWater    []bool  // Optional extra water cells (y*Width + x) for moisture; they stay land for biomes
+	Noise         Noise   // Optional noise added to the moisture
+	NoiseScale    float64 // Cells per noise unit
+	NoiseAmount   float64 // Amplitude of the noise
+
+	// Rain shadow: moisture lost behind terrain higher than the cell along the wind
+	WindX, WindY       float64 // Direction the wind blows towards; zero disables the rain shadow
+	RainShadowDistance int     // Cells searched upwind for barriers
+	RainShadowStrength float64 // Fraction of moisture lost behind the highest barriers (0-1)
+}
+
+// ClimateMaps holds the per-cell climate derived from a heightmap
+type ClimateMaps struct {
+	Temperature *Heightmap // °C
+	Moisture    *Heightmap // 0 (arid) to 1 (wet)
+	Water       []bool     // Whether each cell (y*Width + x) is at or below sea level (FreshWater excluded)
+}
+
+// ComputeClimate derives temperature and moisture maps from the heightmap.
+func ComputeClimate(heightmap *Heightmap, params ClimateParams) *ClimateMaps {
+	startTotal := time.Now()
+	fmt.Printf("Iniciando cálculo del clima (latitud %.1f° a %.1f°, nivel del mar: %.2f)...\n",
+		params.LatitudeTop, params.LatitudeBottom, params.SeaLevel)
+
+	width := heightmap.Width
+	height := heightmap.Height
+	climate := &ClimateMaps{
+		Temperature: NewHeightmap(width, height),
+		Moisture:    NewHeightmap(width, height),
+		Water:       make([]bool, width*height),
+	}
+	climate.Temperature.CellSize = heightmap.CellSize
+	climate.Temperature.Units = "°C"
+	climate.Moisture.CellSize = heightmap.CellSize
+	climate.Moisture.MinValue = 0
+
+	// Temperature from latitude and altitude
+	startTemperature := time.Now()
+	for y := 0; y < height; y++ {
+		t := float64(y) / float64(max(height-1, 1))
+		latitude := (params.LatitudeTop + (params.LatitudeBottom-params.LatitudeTop)*t) * math.Pi / 180
+		seaLevelTemperature := params.PoleTemperature +
+			(params.EquatorTemperature-params.PoleTemperature)*math.Cos(latitude)
+		for x := 0; x < width; x++ {
+			h := heightmap.At(x, y)
+			climate.Water[y*width+x] = h <= params.SeaLevel
+			climate.Temperature.Data[y*width+x] = seaLevelTemperature - params.LapseRate*math.Max(h-params.SeaLevel, 0)
+		}
+	}
+	climate.Temperature.MinValue, climate.Temperature.MaxValue = climate.Temperature.Range()
+	fmt.Printf("  ├─ Temperatura: %.1f a %.1f °C (%.3f ms)\n",
+		climate.Temperature.MinValue, climate.Temperature.MaxValue,
+		float64(time.Since(startTemperature).Microseconds())/1000)
+
+	// Moisture from the distance to water and noise
+	startMoisture := time.Now()
+	sources := climate.Water
+	if params.FreshWater != nil {
+		if len(params.FreshWater) != width*height {
+			fmt.Printf("  ├─ Aviso: FreshWater tiene %d celdas en lugar de %d; se ignora\n",
+				len(params.FreshWater), width*height)
+		} else {
+			sources = make([]bool, width*height)
+			for i := range sources {
+				sources[i] = climate.Water[i] || params.FreshWater[i]
+			}
+		}
+	}
+	toWater := squaredDistanceTransform(sources, width, height)
+	moistureRange := math.Max(params.MoistureRange, 1e-9)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			m := math.Exp(-math.Sqrt(toWater[i]) * heightmap.CellSize / moistureRange)
+			if params.Noise != nil && params.NoiseScale > 0 {
+				m += params.NoiseAmount * params.Noise.Eval2(float64(x)/params.NoiseScale, float64(y)/params.NoiseScale)
+			}
+			climate.Moisture.Data[i] = m
+		}
+	}
+	fmt.Printf("  ├─ Humedad por distancia al agua: %.3f ms\n", float64(time.Since(startMoisture).Microseconds())/1000)
+
+	// Rain shadow: terrain upwind that rises above the cell has already wrung out the rain
+	windLength := math.Hypot(params.WindX, params.WindY)
+	if windLength > 0 && params.RainShadowDistance > 0 && params.RainShadowStrength > 0 {
+		startShadow := time.Now()
+		stepX, stepY := -params.WindX/windLength, -params.WindY/windLength
+		lo, hi := heightmap.Range()
+		relief := math.Max(hi-math.Max(lo, params.SeaLevel), 1e-9)
+		shadowed := 0
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				i := y*width + x
+				if climate.Water[i] {
+					continue
+				}
+				h := heightmap.At(x, y)
+				barrier := h
+				for s := 1; s <= params.RainShadowDistance; s++ {
+					sx := float64(x) + stepX*float64(s)
+					sy := float64(y) + stepY*float64(s)
+					if sx < 0 || sy < 0 || sx > float64(width-1) || sy > float64(height-1) {
+						break
+					}
+					barrier = math.Max(barrier, heightmap.Sample(sx, sy))
+				}
+				if barrier > h {
+					climate.Moisture.Data[i] *= 1 - params.RainShadowStrength*math.Min((barrier-h)/relief, 1)
+					shadowed++
+				}
+			}
+		}
+		fmt.Printf("  ├─ Sombra orográfica: %d celdas afectadas (%.3f ms)\n",
+			shadowed, float64(time.Since(startShadow).Microseconds())/1000)
+	}
+
+	for i, m := range climate.Moisture.Data {
+		climate.Moisture.Data[i] = math.Max(0, math.Min(1, m))
+	}
+
+	fmt.Printf("  └─ Tiempo total de cálculo del clima: %.3f ms\n", float64(time.Since(startTotal).Microseconds())/1000)
+	return climate
+}
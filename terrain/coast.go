@@ -0,0 +1,356 @@
+package terrain
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"time"
+)
+
+// Coastline es una polilínea a lo largo de la curva de nivel del mar, en coordenadas de celda
+type Coastline struct {
+	Points [][2]float64
+	Closed bool // El último punto repite el primero (islas y lagos lejos del borde del mapa)
+}
+
+// CoastMap describe la tierra, el mar y la frontera entre ambos a un nivel del mar dado
+type CoastMap struct {
+	Width      int
+	Height     int
+	CellSize   float64
+	SeaLevel   float64
+	Land       []bool     // Si cada celda (y*Width + x) está por encima del nivel del mar
+	Distance   *Heightmap // Distancia con signo a la costa: positiva en tierra, negativa en el mar (limitada a la diagonal del mapa)
+	Coastlines []Coastline
+}
+
+// ExtractCoast divide el heightmap en tierra (por encima de seaLevel) y agua, calcula la
+// distancia con signo de cada celda a la costa con una transformada de distancia euclídea
+// exacta y traza las líneas de costa con marching squares.
+func ExtractCoast(heightmap *Heightmap, seaLevel float64) *CoastMap {
+	startTotal := time.Now()
+	fmt.Printf("Iniciando extracción de costa (nivel del mar: %.3f)...\n", seaLevel)
+
+	width := heightmap.Width
+	height := heightmap.Height
+	coast := &CoastMap{
+		Width:    width,
+		Height:   height,
+		CellSize: heightmap.CellSize,
+		SeaLevel: seaLevel,
+		Land:     make([]bool, width*height),
+	}
+
+	landCells := 0
+	water := make([]bool, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			coast.Land[i] = heightmap.At(x, y) > seaLevel
+			water[i] = !coast.Land[i]
+			if coast.Land[i] {
+				landCells++
+			}
+		}
+	}
+	fmt.Printf("  ├─ Tierra: %.1f%% de las celdas\n", float64(landCells)/float64(max(width*height, 1))*100)
+
+	// Distancia de la tierra a la celda de agua más cercana y viceversa; la costa está a media
+	// celda de los centros a cada lado. Los mapas solo de tierra o solo de agua no tienen costa,
+	// así que las distancias se limitan a la diagonal del mapa en lugar de +Inf para que
+	// Range() sea finito.
+	startDistance := time.Now()
+	diagonal := math.Hypot(float64(width), float64(height))
+	toWater := squaredDistanceTransform(water, width, height)
+	toLand := squaredDistanceTransform(coast.Land, width, height)
+	coast.Distance = NewHeightmap(width, height)
+	coast.Distance.CellSize = heightmap.CellSize
+	coast.Distance.Units = heightmap.Units
+	for i := range coast.Distance.Data {
+		if coast.Land[i] {
+			coast.Distance.Data[i] = (math.Min(math.Sqrt(toWater[i]), diagonal) - 0.5) * heightmap.CellSize
+		} else {
+			coast.Distance.Data[i] = -(math.Min(math.Sqrt(toLand[i]), diagonal) - 0.5) * heightmap.CellSize
+		}
+	}
+	coast.Distance.MinValue, coast.Distance.MaxValue = coast.Distance.Range()
+	fmt.Printf("  ├─ Distancia con signo a la costa: %.3f ms\n", float64(time.Since(startDistance).Microseconds())/1000)
+
+	startContours := time.Now()
+	coast.Coastlines = marchingSquares(heightmap, seaLevel)
+	points := 0
+	for _, c := range coast.Coastlines {
+		points += len(c.Points)
+	}
+	fmt.Printf("  ├─ Líneas de costa: %d (%d puntos): %.3f ms\n",
+		len(coast.Coastlines), points, float64(time.Since(startContours).Microseconds())/1000)
+
+	fmt.Printf("  └─ Tiempo total de extracción de costa: %.3f ms\n", float64(time.Since(startTotal).Microseconds())/1000)
+	return coast
+}
+
+// distanceInfinity representa "aún sin celda objetivo" en la transformada de distancia; es
+// finito para que las intersecciones de parábolas nunca calculen Inf - Inf
+const distanceInfinity = 1e20
+
+// squaredDistanceTransform devuelve el cuadrado de la distancia euclídea, en celdas, de cada
+// celda a la celda más cercana donde feature es true (Felzenszwalb y Huttenlocher, 2012).
+// Si no hay ninguna, todas las distancias son +Inf.
+func squaredDistanceTransform(feature []bool, width, height int) []float64 {
+	dist := make([]float64, width*height)
+	for i, f := range feature {
+		if !f {
+			dist[i] = distanceInfinity
+		}
+	}
+
+	n := max(width, height)
+	f := make([]float64, n)
+	d := make([]float64, n)
+	v := make([]int, n)
+	z := make([]float64, n+1)
+
+	// Primero columnas, después filas
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			f[y] = dist[y*width+x]
+		}
+		distanceTransform1D(f[:height], d[:height], v, z)
+		for y := 0; y < height; y++ {
+			dist[y*width+x] = d[y]
+		}
+	}
+	for y := 0; y < height; y++ {
+		copy(f[:width], dist[y*width:(y+1)*width])
+		distanceTransform1D(f[:width], d[:width], v, z)
+		copy(dist[y*width:(y+1)*width], d[:width])
+	}
+
+	for i := range dist {
+		if dist[i] >= distanceInfinity/2 {
+			dist[i] = math.Inf(1)
+		}
+	}
+	return dist
+}
+
+// distanceTransform1D calcula d[q] = mínimo sobre p de (q - p)^2 + f[p] con la envolvente
+// inferior de parábolas; v y z son búferes auxiliares de al menos len(f) y len(f)+1
+func distanceTransform1D(f, d []float64, v []int, z []float64) {
+	k := 0
+	v[0] = 0
+	z[0] = math.Inf(-1)
+	z[1] = math.Inf(1)
+	for q := 1; q < len(f); q++ {
+		s := ((f[q] + float64(q*q)) - (f[v[k]] + float64(v[k]*v[k]))) / float64(2*q-2*v[k])
+		for s <= z[k] {
+			k--
+			s = ((f[q] + float64(q*q)) - (f[v[k]] + float64(v[k]*v[k]))) / float64(2*q-2*v[k])
+		}
+		k++
+		v[k] = q
+		z[k] = s
+		z[k+1] = math.Inf(1)
+	}
+
+	k = 0
+	for q := range f {
+		for z[k+1] < float64(q) {
+			k++
+		}
+		dq := float64(q - v[k])
+		d[q] = dq*dq + f[v[k]]
+	}
+}
+
+// marchingSquares traza la curva donde el heightmap cruza level entre los centros de las
+// celdas y une los fragmentos en polilíneas. Los puntos de silla se resuelven con la media
+// de las cuatro esquinas.
+func marchingSquares(heightmap *Heightmap, level float64) []Coastline {
+	width := heightmap.Width
+	height := heightmap.Height
+
+	// Cada punto de cruce se identifica por la arista de la rejilla en la que está:
+	// 2*(y*width+x) para la arista de (x, y) a (x+1, y), +1 para la de (x, y) a (x, y+1)
+	points := make(map[int][2]float64)
+	crossing := func(x0, y0, x1, y1 int) int {
+		id := 2 * (y0*width + x0)
+		if y1 != y0 {
+			id++
+		}
+		if _, ok := points[id]; !ok {
+			a := heightmap.At(x0, y0) - level
+			b := heightmap.At(x1, y1) - level
+			t := a / (a - b)
+			points[id] = [2]float64{float64(x0) + t*float64(x1-x0), float64(y0) + t*float64(y1-y0)}
+		}
+		return id
+	}
+
+	var segments [][2]int
+	for y := 0; y+1 < height; y++ {
+		for x := 0; x+1 < width; x++ {
+			// Esquinas en sentido horario desde la superior izquierda
+			a := heightmap.At(x, y) > level
+			b := heightmap.At(x+1, y) > level
+			c := heightmap.At(x+1, y+1) > level
+			d := heightmap.At(x, y+1) > level
+
+			var edges []int
+			if a != b {
+				edges = append(edges, crossing(x, y, x+1, y)) // Arriba
+			}
+			if b != c {
+				edges = append(edges, crossing(x+1, y, x+1, y+1)) // Derecha
+			}
+			if d != c {
+				edges = append(edges, crossing(x, y+1, x+1, y+1)) // Abajo
+			}
+			if a != d {
+				edges = append(edges, crossing(x, y, x, y+1)) // Izquierda
+			}
+
+			switch len(edges) {
+			case 2:
+				segments = append(segments, [2]int{edges[0], edges[1]})
+			case 4:
+				top, right, bottom, left := edges[0], edges[1], edges[2], edges[3]
+				centre := (heightmap.At(x, y)+heightmap.At(x+1, y)+heightmap.At(x+1, y+1)+heightmap.At(x, y+1))/4 > level
+				if a == centre {
+					// El centro une a y c: se separan las esquinas b y d
+					segments = append(segments, [2]int{top, right}, [2]int{bottom, left})
+				} else {
+					segments = append(segments, [2]int{top, left}, [2]int{right, bottom})
+				}
+			}
+		}
+	}
+
+	// Cada punto de cruce une como mucho dos segmentos
+	bySegmentEnd := make(map[int][]int, len(points))
+	for s, seg := range segments {
+		bySegmentEnd[seg[0]] = append(bySegmentEnd[seg[0]], s)
+		bySegmentEnd[seg[1]] = append(bySegmentEnd[seg[1]], s)
+	}
+
+	used := make([]bool, len(segments))
+	trace := func(s, from int) Coastline {
+		line := Coastline{Points: [][2]float64{points[from]}}
+		start := from
+		for {
+			used[s] = true
+			to := segments[s][0]
+			if to == from {
+				to = segments[s][1]
+			}
+			line.Points = append(line.Points, points[to])
+			if to == start {
+				line.Closed = true
+				return line
+			}
+
+			next := -1
+			for _, o := range bySegmentEnd[to] {
+				if !used[o] {
+					next = o
+				}
+			}
+			if next < 0 {
+				return line
+			}
+			s, from = next, to
+		}
+	}
+
+	// Las líneas abiertas empiezan donde tocan el borde del mapa; lo que queda forma bucles.
+	// Se descartan las líneas que se reducen a un punto (una esquina justo a la altura level).
+	var lines []Coastline
+	keep := func(line Coastline) {
+		for _, p := range line.Points {
+			if p != line.Points[0] {
+				lines = append(lines, line)
+				return
+			}
+		}
+	}
+	for s, seg := range segments {
+		for _, end := range seg {
+			if !used[s] && len(bySegmentEnd[end]) == 1 {
+				keep(trace(s, end))
+			}
+		}
+	}
+	for s, seg := range segments {
+		if !used[s] {
+			keep(trace(s, seg[0]))
+		}
+	}
+	return lines
+}
+
+// Mask devuelve la máscara de tierra: 255 en tierra firme y 0 en el agua
+func (coast *CoastMap) Mask() *image.Gray {
+	mask := image.NewGray(image.Rect(0, 0, coast.Width, coast.Height))
+	for i, land := range coast.Land {
+		if land {
+			mask.SetGray(i%coast.Width, i/coast.Width, color.Gray{Y: 255})
+		}
+	}
+	return mask
+}
+
+// SaveLandMaskPNG guarda la máscara de tierra y agua como PNG en escala de grises
+func SaveLandMaskPNG(filename string, coast *CoastMap) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return png.Encode(file, coast.Mask())
+}
+
+// SaveCoastlinesGeoJSON guarda las líneas de costa como LineStrings en unidades del mapa
+func SaveCoastlinesGeoJSON(filename string, coast *CoastMap) error {
+	features := make([]geoJSONFeature, 0, len(coast.Coastlines))
+	for _, c := range coast.Coastlines {
+		features = append(features, newGeoJSONFeature(
+			geoJSONLineString(c.Points, coast.CellSize),
+			map[string]any{"closed": c.Closed, "sea_level": coast.SeaLevel},
+		))
+	}
+	return writeGeoJSON(filename, features)
+}
+
+// SaveCoastlinesSVG guarda las líneas de costa como trazados SVG en coordenadas de celda
+func SaveCoastlinesSVG(filename string, coast *CoastMap) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+
+	fmt.Fprintf(writer, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n",
+		coast.Width, coast.Height, coast.Width-1, coast.Height-1)
+	fmt.Fprintf(writer, "<g fill=\"none\" stroke=\"#0077BE\" stroke-width=\"1\" stroke-linejoin=\"round\">\n")
+	for _, c := range coast.Coastlines {
+		fmt.Fprintf(writer, "<path d=\"")
+		for i, p := range c.Points {
+			if i == 0 {
+				fmt.Fprintf(writer, "M%.2f %.2f", p[0], p[1])
+			} else if c.Closed && i == len(c.Points)-1 {
+				fmt.Fprintf(writer, "Z")
+			} else {
+				fmt.Fprintf(writer, "L%.2f %.2f", p[0], p[1])
+			}
+		}
+		fmt.Fprintf(writer, "\"/>\n")
+	}
+	fmt.Fprintf(writer, "</g>\n</svg>\n")
+
+	return writer.Flush()
+}
@@ -0,0 +1,468 @@
+package terrain
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"time"
+)
+
+// DecimateParams sets when the simplification stops: at whichever limit is reached first.
+// Without any limit nothing is removed.
+type DecimateParams struct {
+	TargetTriangles int     // Stop once the mesh has at most this many triangles (0: no limit)
+	MaxError        float64 // Largest quadric error allowed per collapse, as a distance (0: no limit)
+}
+
+// MeshLOD is one level of detail of a mesh, in the layout GenerateHeightmapMesh returns
+type MeshLOD struct {
+	Vertices [][3]float64
+	Faces    [][3]int
+	Colors   [][3]float64 // nil when the input had no colours
+	Error    float64      // Quadric error of the costliest collapse made so far, as a distance (RTIN: the error bound)
+}
+
+// minCollapseNormalDot rejects collapses that rotate any surrounding face by more than
+// about 78°, which also catches faces folding over
+const minCollapseNormalDot = 0.2
+
+// minHeightfieldDot keeps the faces of a height field from turning vertical or over
+const minHeightfieldDot = 1e-3
+
+// quadric is a symmetric 4x4 error quadric (Garland & Heckbert, 1997) stored as its upper
+// triangle. Evaluated at a point it gives the sum of the squared distances to the planes
+// accumulated into it, so its square root bounds the distance to each of those planes.
+type quadric [10]float64
+
+// planeQuadric returns the quadric of the triangle's plane
+func planeQuadric(a, b, c [3]float64) quadric {
+	n := faceNormal(a, b, c)
+	if n == ([3]float64{}) {
+		return quadric{}
+	}
+	n = unitVector(n)
+	d := -(n[0]*a[0] + n[1]*a[1] + n[2]*a[2])
+	return quadric{
+		n[0] * n[0], n[0] * n[1], n[0] * n[2], n[0] * d,
+		n[1] * n[1], n[1] * n[2], n[1] * d,
+		n[2] * n[2], n[2] * d,
+		d * d,
+	}
+}
+
+func (q *quadric) add(o *quadric) {
+	for i := range q {
+		q[i] += o[i]
+	}
+}
+
+// squaredDistance returns the sum of the squared distances from p to the planes
+// accumulated in q and o
+func (q *quadric) squaredDistance(o *quadric, p [3]float64) float64 {
+	var s quadric
+	for i := range s {
+		s[i] = q[i] + o[i]
+	}
+	x, y, z := p[0], p[1], p[2]
+	e := s[0]*x*x + 2*s[1]*x*y + 2*s[2]*x*z + 2*s[3]*x +
+		s[4]*y*y + 2*s[5]*y*z + 2*s[6]*y +
+		s[7]*z*z + 2*s[8]*z +
+		s[9]
+	return math.Max(e, 0)
+}
+
+// collapse is a candidate half-edge collapse: vertex from moves onto vertex to
+type collapse struct {
+	cost        float64
+	from, to    int32
+	fromVersion uint32
+	toVersion   uint32
+	reversed    bool // The opposite direction was tried first
+}
+
+// collapseQueue is a min-heap of collapses ordered by cost
+type collapseQueue []collapse
+
+func (q collapseQueue) Len() int { return len(q) }
+func (q collapseQueue) Less(i, j int) bool {
+	// Ties are broken by the vertices so the result is deterministic
+	if q[i].cost != q[j].cost {
+		return q[i].cost < q[j].cost
+	}
+	if q[i].from != q[j].from {
+		return q[i].from < q[j].from
+	}
+	if q[i].to != q[j].to {
+		return q[i].to < q[j].to
+	}
+	return q[i].fromVersion+q[i].toVersion < q[j].fromVersion+q[j].toVersion
+}
+func (q collapseQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *collapseQueue) Push(x any)   { *q = append(*q, x.(collapse)) }
+func (q *collapseQueue) Pop() any {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// decimator simplifies an indexed mesh by greedy half-edge collapses, so the kept vertices
+// (and their colours) are a subset of the original ones
+type decimator struct {
+	positions   [][3]float64
+	colors      [][3]float64
+	faces       [][3]int32
+	faceAlive   []bool
+	vertexFaces [][]int32 // Faces around each vertex; may hold dead faces until pruned
+	quadrics    []quadric
+	locked      []bool // Border vertices never move
+	version     []uint32
+	queue       collapseQueue
+	alive       int // Live faces
+	maxCost     float64
+	up          [3]float64 // Axis every face of a height field faces along; zero for other meshes
+
+	// Scratch space for ring
+	fromRing, toRing []ringVertex
+	stamps, slots    []int32
+	stamp            int32
+}
+
+func newDecimator(vertices [][3]float64, faces [][3]int, colors [][3]float64) *decimator {
+	d := &decimator{
+		positions:   vertices,
+		colors:      colors,
+		faces:       make([][3]int32, len(faces)),
+		faceAlive:   make([]bool, len(faces)),
+		vertexFaces: make([][]int32, len(vertices)),
+		quadrics:    make([]quadric, len(vertices)),
+		locked:      make([]bool, len(vertices)),
+		version:     make([]uint32, len(vertices)),
+		stamps:      make([]int32, len(vertices)),
+		slots:       make([]int32, len(vertices)),
+	}
+
+	degree := make([]int32, len(vertices))
+	for _, f := range faces {
+		for _, v := range f {
+			degree[v]++
+		}
+	}
+	for v := range d.vertexFaces {
+		d.vertexFaces[v] = make([]int32, 0, degree[v])
+	}
+	for i, f := range faces {
+		d.faces[i] = [3]int32{int32(f[0]), int32(f[1]), int32(f[2])}
+		if f[0] == f[1] || f[1] == f[2] || f[0] == f[2] {
+			continue
+		}
+		d.faceAlive[i] = true
+		d.alive++
+		q := planeQuadric(vertices[f[0]], vertices[f[1]], vertices[f[2]])
+		for _, v := range f {
+			d.vertexFaces[v] = append(d.vertexFaces[v], int32(i))
+			d.quadrics[v].add(&q)
+		}
+	}
+
+	// Height fields (all faces facing the same way along the axis the normals add up to
+	// most) must stay height fields
+	var total [3]float64
+	for i, f := range d.faces {
+		if d.faceAlive[i] {
+			n := faceNormal(vertices[f[0]], vertices[f[1]], vertices[f[2]])
+			total = [3]float64{total[0] + n[0], total[1] + n[1], total[2] + n[2]}
+		}
+	}
+	axis := 0
+	for k := 1; k < 3; k++ {
+		if math.Abs(total[k]) > math.Abs(total[axis]) {
+			axis = k
+		}
+	}
+	d.up[axis] = math.Copysign(1, total[axis])
+	for i, f := range d.faces {
+		if d.faceAlive[i] {
+			n := faceNormal(vertices[f[0]], vertices[f[1]], vertices[f[2]])
+			if n[0]*d.up[0]+n[1]*d.up[1]+n[2]*d.up[2] <= 0 {
+				d.up = [3]float64{}
+				break
+			}
+		}
+	}
+
+	// An edge used by a single face lies on the border; its ends are locked so tiles
+	// decimated separately still share the same border vertices. Non-manifold edges too.
+	for v := range vertices {
+		d.fromRing = d.ring(int32(v), d.fromRing)
+		for _, r := range d.fromRing {
+			if r.faces != 2 {
+				d.locked[v] = true
+				d.locked[r.vertex] = true
+			}
+		}
+	}
+
+	for v := range vertices {
+		d.fromRing = d.ring(int32(v), d.fromRing)
+		for _, r := range d.fromRing {
+			if int32(v) < r.vertex {
+				d.pushEdge(int32(v), r.vertex)
+			}
+		}
+	}
+	heap.Init(&d.queue)
+	return d
+}
+
+// ringVertex is a neighbour of a vertex and the number of live faces sharing their edge
+type ringVertex struct {
+	vertex int32
+	faces  int
+}
+
+// ring prunes the dead faces around v and appends its neighbours to buf[:0]. Until the
+// next call, ringFaces looks up vertices in the returned ring.
+func (d *decimator) ring(v int32, buf []ringVertex) []ringVertex {
+	d.stamp++
+	buf = buf[:0]
+	live := d.vertexFaces[v][:0]
+	for _, fi := range d.vertexFaces[v] {
+		if !d.faceAlive[fi] {
+			continue
+		}
+		live = append(live, fi)
+		for _, w := range d.faces[fi] {
+			if w == v {
+				continue
+			}
+			if d.stamps[w] == d.stamp {
+				buf[d.slots[w]].faces++
+				continue
+			}
+			d.stamps[w] = d.stamp
+			d.slots[w] = int32(len(buf))
+			buf = append(buf, ringVertex{w, 1})
+		}
+	}
+	d.vertexFaces[v] = live
+	return buf
+}
+
+// ringFaces returns the number of faces w shares with the centre of the last ring
+func (d *decimator) ringFaces(ring []ringVertex, w int32) int {
+	if d.stamps[w] != d.stamp {
+		return 0
+	}
+	return ring[d.slots[w]].faces
+}
+
+// pushEdge queues the cheaper allowed direction of the edge between a and b
+func (d *decimator) pushEdge(a, b int32) {
+	c := collapse{cost: math.Inf(1)}
+	if !d.locked[a] {
+		c = d.candidate(a, b)
+	}
+	if !d.locked[b] {
+		if o := d.candidate(b, a); o.cost < c.cost {
+			c = o
+		}
+	}
+	if !math.IsInf(c.cost, 1) {
+		d.queue = append(d.queue, c)
+	}
+}
+
+func (d *decimator) candidate(from, to int32) collapse {
+	return collapse{
+		cost:        d.quadrics[from].squaredDistance(&d.quadrics[to], d.positions[to]),
+		from:        from,
+		to:          to,
+		fromVersion: d.version[from],
+		toVersion:   d.version[to],
+	}
+}
+
+// canCollapse checks that moving from onto to keeps the mesh manifold (the link
+// condition) and does not fold or badly rotate any face that survives the collapse, nor
+// turn a height field face vertical
+func (d *decimator) canCollapse(from, to int32) bool {
+	d.fromRing = d.ring(from, d.fromRing)
+	shared := d.ringFaces(d.fromRing, to)
+	if shared == 0 {
+		return false
+	}
+	d.toRing = d.ring(to, d.toRing)
+	common := 0
+	for _, r := range d.fromRing {
+		if d.ringFaces(d.toRing, r.vertex) > 0 {
+			common++
+		}
+	}
+	if common != shared {
+		return false
+	}
+
+	for _, fi := range d.vertexFaces[from] {
+		f := d.faces[fi]
+		if f[0] == to || f[1] == to || f[2] == to {
+			continue
+		}
+		var before, after [3][3]float64
+		for k, v := range f {
+			before[k] = d.positions[v]
+			after[k] = d.positions[v]
+			if v == from {
+				after[k] = d.positions[to]
+			}
+		}
+		n0 := unitVector(faceNormal(before[0], before[1], before[2]))
+		n1 := faceNormal(after[0], after[1], after[2])
+		if n1 == ([3]float64{}) {
+			return false
+		}
+		n1 = unitVector(n1)
+		if n0[0]*n1[0]+n0[1]*n1[1]+n0[2]*n1[2] < minCollapseNormalDot {
+			return false
+		}
+		if d.up != ([3]float64{}) && n1[0]*d.up[0]+n1[1]*d.up[1]+n1[2]*d.up[2] < minHeightfieldDot {
+			return false
+		}
+	}
+	return true
+}
+
+// apply moves from onto to, removes the faces they shared and requeues the edges around to
+func (d *decimator) apply(c collapse) {
+	from, to := c.from, c.to
+	for _, fi := range d.vertexFaces[from] {
+		if !d.faceAlive[fi] {
+			continue
+		}
+		f := &d.faces[fi]
+		if f[0] == to || f[1] == to || f[2] == to {
+			d.faceAlive[fi] = false
+			d.alive--
+			continue
+		}
+		for k := range f {
+			if f[k] == from {
+				f[k] = to
+			}
+		}
+		d.vertexFaces[to] = append(d.vertexFaces[to], fi)
+	}
+	d.vertexFaces[from] = nil
+	d.quadrics[to].add(&d.quadrics[from])
+	d.version[from]++
+	d.version[to]++
+	d.maxCost = math.Max(d.maxCost, c.cost)
+
+	d.toRing = d.ring(to, d.toRing)
+	for _, r := range d.toRing {
+		n := len(d.queue)
+		d.pushEdge(to, r.vertex)
+		if len(d.queue) > n {
+			heap.Fix(&d.queue, n)
+		}
+	}
+}
+
+// run collapses edges until params' limits are reached or nothing else can collapse
+func (d *decimator) run(params DecimateParams) {
+	maxCost := math.Inf(1)
+	if params.MaxError > 0 {
+		maxCost = params.MaxError * params.MaxError
+	}
+	for d.queue.Len() > 0 && d.alive > params.TargetTriangles {
+		c := heap.Pop(&d.queue).(collapse)
+		if c.fromVersion != d.version[c.from] || c.toVersion != d.version[c.to] {
+			continue
+		}
+		if c.cost > maxCost {
+			// Later levels with a larger bound continue from here
+			heap.Push(&d.queue, c)
+			return
+		}
+		if !d.canCollapse(c.from, c.to) {
+			if !c.reversed && !d.locked[c.to] {
+				r := d.candidate(c.to, c.from)
+				r.reversed = true
+				heap.Push(&d.queue, r)
+			}
+			continue
+		}
+		d.apply(c)
+	}
+}
+
+// mesh returns the current mesh with the unused vertices removed
+func (d *decimator) mesh() MeshLOD {
+	remap := make([]int, len(d.positions))
+	for i := range remap {
+		remap[i] = -1
+	}
+	lod := MeshLOD{Faces: make([][3]int, 0, d.alive), Error: math.Sqrt(d.maxCost)}
+	for fi, f := range d.faces {
+		if !d.faceAlive[fi] {
+			continue
+		}
+		var face [3]int
+		for k, v := range f {
+			if remap[v] < 0 {
+				remap[v] = len(lod.Vertices)
+				lod.Vertices = append(lod.Vertices, d.positions[v])
+				if d.colors != nil {
+					lod.Colors = append(lod.Colors, d.colors[v])
+				}
+			}
+			face[k] = remap[v]
+		}
+		lod.Faces = append(lod.Faces, face)
+	}
+	return lod
+}
+
+// DecimateMesh simplifies the mesh with quadric error metrics, keeping its border vertices
+// in place. Vertices are never moved, only removed, so colours and UVs stay valid.
+func DecimateMesh(vertices [][3]float64, faces [][3]int, colors [][3]float64, params DecimateParams) ([][3]float64, [][3]int, [][3]float64) {
+	lod := GenerateLODs(vertices, faces, colors, []DecimateParams{params})[0]
+	return lod.Vertices, lod.Faces, lod.Colors
+}
+
+// GenerateLODs builds one simplified mesh per level in a single decimation pass, so every
+// level's error is measured against the original mesh. Levels must go from finest to
+// coarsest (decreasing TargetTriangles, increasing MaxError); a level without limits
+// repeats the previous one.
+func GenerateLODs(vertices [][3]float64, faces [][3]int, colors [][3]float64, levels []DecimateParams) []MeshLOD {
+	startTotal := time.Now()
+	fmt.Printf("Iniciando simplificación de malla (%d vértices, %d triángulos, %d niveles)...\n",
+		len(vertices), len(faces), len(levels))
+
+	startSetup := time.Now()
+	d := newDecimator(vertices, faces, colors)
+	lockedCount := 0
+	for _, l := range d.locked {
+		if l {
+			lockedCount++
+		}
+	}
+	fmt.Printf("  ├─ Cuádricas y cola de colapsos: %.3f ms (%d vértices de borde bloqueados)\n",
+		float64(time.Since(startSetup).Microseconds())/1000, lockedCount)
+
+	lods := make([]MeshLOD, len(levels))
+	for k, params := range levels {
+		startLevel := time.Now()
+		if params.TargetTriangles > 0 || params.MaxError > 0 {
+			d.run(params)
+		}
+		lods[k] = d.mesh()
+		fmt.Printf("  ├─ LOD %d: %d vértices, %d triángulos (%.1f%%), error %.4f: %.3f ms\n",
+			k, len(lods[k].Vertices), len(lods[k].Faces),
+			float64(len(lods[k].Faces))/float64(max(len(faces), 1))*100, lods[k].Error,
+			float64(time.Since(startLevel).Microseconds())/1000)
+	}
+
+	fmt.Printf("  └─ Tiempo total de simplificación: %.3f ms\n", float64(time.Since(startTotal).Microseconds())/1000)
+	return lods
+}
@@ -0,0 +1,23 @@
+package terrain
+
+// Eroder is an erosion model that turns a heightmap into an eroded copy.
+// Every simulator in the package implements it so they can be swapped in the pipeline.
+type Eroder interface {
+	Erode(heightmap *Heightmap) *Heightmap
+}
+
+// DropletErosion adapts the particle-based ApplyErosion to the Eroder interface
+type DropletErosion struct {
+	Droplets int
+	Params   ErosionParams
+}
+
+// Erode runs ApplyErosion with the configured droplet count and parameters
+func (d DropletErosion) Erode(heightmap *Heightmap) *Heightmap {
+	return ApplyErosion(heightmap, d.Droplets, d.Params)
+}
+
+// Erode runs ApplyThermalErosion with these parameters
+func (p ThermalParams) Erode(heightmap *Heightmap) *Heightmap {
+	return ApplyThermalErosion(heightmap, p)
+}
@@ -0,0 +1,280 @@
+package terrain
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// FlowMethod selects how water is routed from each cell to its neighbours
+type FlowMethod int
+
+const (
+	FlowD8        FlowMethod = iota // All flow to the steepest of the 8 neighbours
+	FlowDInfinity                   // Flow split between two neighbours along the steepest facet (Tarboton, 1997)
+)
+
+// String returns the name of the flow routing method
+func (m FlowMethod) String() string {
+	switch m {
+	case FlowD8:
+		return "D8"
+	case FlowDInfinity:
+		return "D-infinito"
+	default:
+		return fmt.Sprintf("FlowMethod(%d)", int(m))
+	}
+}
+
+// FlowField holds the flow directions and accumulation of a heightmap.
+// Cells are indexed y*Width + x.
+type FlowField struct {
+	Width        int
+	Height       int
+	CellSize     float64
+	Method       FlowMethod
+	Receiver     []int      // Neighbour receiving most of the flow; the cell itself for pits and outlets
+	Receiver2    []int      // D-infinity: neighbour receiving the rest of the flow, -1 if none
+	Fraction     []float64  // Share of the flow sent to Receiver (always 1 for D8)
+	Angle        []float64  // Flow direction in radians from +x towards +y; NaN where water does not leave
+	Accumulation *Heightmap // Area draining through each cell, in cells (including the cell itself)
+}
+
+// d8Offsets lists the eight neighbours visited by D8 flow routing
+var d8Offsets = [8][2]int{
+	{1, 0}, {-1, 0}, {0, 1}, {0, -1},
+	{1, 1}, {-1, 1}, {1, -1}, {-1, -1},
+}
+
+// dInfFacets lists Tarboton's eight triangular facets as a cardinal and a diagonal
+// neighbour, with the side of the cardinal direction the diagonal lies on
+var dInfFacets = [8]struct {
+	cardinal, diagonal [2]int
+	side               float64
+}{
+	{[2]int{1, 0}, [2]int{1, 1}, 1}, {[2]int{1, 0}, [2]int{1, -1}, -1},
+	{[2]int{0, -1}, [2]int{1, -1}, 1}, {[2]int{0, -1}, [2]int{-1, -1}, -1},
+	{[2]int{-1, 0}, [2]int{-1, -1}, 1}, {[2]int{-1, 0}, [2]int{-1, 1}, -1},
+	{[2]int{0, 1}, [2]int{-1, 1}, 1}, {[2]int{0, 1}, [2]int{1, 1}, -1},
+}
+
+// d8Receivers routes every cell to its steepest downhill neighbour (D8). Cells with no
+// lower neighbour, and edge cells when edgesAreOutlets is set, are their own receiver.
+// dist holds the horizontal distance to the receiver.
+func d8Receivers(heightmap *Heightmap, cellSize float64, edgesAreOutlets bool) (receivers []int, dist []float64) {
+	width := heightmap.Width
+	height := heightmap.Height
+	receivers = make([]int, width*height)
+	dist = make([]float64, width*height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			receivers[i] = i
+			if edgesAreOutlets && (x == 0 || y == 0 || x == width-1 || y == height-1) {
+				continue
+			}
+
+			h := heightmap.At(x, y)
+			steepest := 0.0
+			for _, o := range d8Offsets {
+				nx, ny := x+o[0], y+o[1]
+				if nx < 0 || nx >= width || ny < 0 || ny >= height {
+					continue
+				}
+				d := cellSize * math.Hypot(float64(o[0]), float64(o[1]))
+				if slope := (h - heightmap.At(nx, ny)) / d; slope > steepest {
+					steepest = slope
+					receivers[i] = ny*width + nx
+					dist[i] = d
+				}
+			}
+		}
+	}
+	return receivers, dist
+}
+
+// flowStack orders the cells so every cell comes after its receiver (Braun & Willett's
+// stack): walking it forwards goes downstream to upstream, backwards accumulates flow.
+func flowStack(receivers []int) []int {
+	// Donors of each cell in compressed (CSR) form
+	donorStart := make([]int, len(receivers)+1)
+	for i, r := range receivers {
+		if r != i {
+			donorStart[r+1]++
+		}
+	}
+	for i := range receivers {
+		donorStart[i+1] += donorStart[i]
+	}
+	donors := make([]int, donorStart[len(receivers)])
+	next := append([]int(nil), donorStart[:len(receivers)]...)
+	for i, r := range receivers {
+		if r != i {
+			donors[next[r]] = i
+			next[r]++
+		}
+	}
+
+	// Depth-first walk up from every base level
+	stack := make([]int, 0, len(receivers))
+	pending := make([]int, 0, 64)
+	for i, r := range receivers {
+		if r != i {
+			continue
+		}
+		pending = append(pending, i)
+		for len(pending) > 0 {
+			c := pending[len(pending)-1]
+			pending = pending[:len(pending)-1]
+			stack = append(stack, c)
+			pending = append(pending, donors[donorStart[c]:donorStart[c+1]]...)
+		}
+	}
+	return stack
+}
+
+// ComputeFlow routes water over the heightmap with the given method and accumulates the
+// area draining through every cell. Water leaves the map only where an edge cell has no
+// lower neighbour; pits keep their water, so fill depressions first for connected rivers.
+func ComputeFlow(heightmap *Heightmap, method FlowMethod) *FlowField {
+	startTotal := time.Now()
+	fmt.Printf("Iniciando cálculo de flujo %s (%dx%d)...\n", method, heightmap.Width, heightmap.Height)
+
+	width := heightmap.Width
+	height := heightmap.Height
+	n := width * height
+	flow := &FlowField{
+		Width:     width,
+		Height:    height,
+		CellSize:  heightmap.CellSize,
+		Method:    method,
+		Receiver2: make([]int, n),
+		Fraction:  make([]float64, n),
+		Angle:     make([]float64, n),
+	}
+
+	startDirections := time.Now()
+	if method == FlowDInfinity {
+		flow.Receiver = make([]int, n)
+		computeDInfinity(heightmap, flow)
+	} else {
+		flow.Receiver, _ = d8Receivers(heightmap, heightmap.CellSize, false)
+		for i, r := range flow.Receiver {
+			flow.Receiver2[i] = -1
+			flow.Fraction[i] = 1
+			flow.Angle[i] = math.NaN()
+			if r != i {
+				flow.Angle[i] = math.Atan2(float64(r/width-i/width), float64(r%width-i%width))
+			}
+		}
+	}
+	fmt.Printf("  ├─ Direcciones de flujo: %.3f ms\n", float64(time.Since(startDirections).Microseconds())/1000)
+
+	// Visit cells from highest to lowest so every donor is done before its receivers
+	startAccumulation := time.Now()
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		ia, ib := order[a], order[b]
+		return heightmap.At(ia%width, ia/width) > heightmap.At(ib%width, ib/width)
+	})
+
+	acc := NewHeightmap(width, height)
+	acc.CellSize = heightmap.CellSize
+	acc.Units = "celdas"
+	for i := range acc.Data {
+		acc.Data[i] = 1
+	}
+	pits := 0
+	for _, c := range order {
+		r := flow.Receiver[c]
+		if r == c {
+			pits++
+			continue
+		}
+		acc.Data[r] += acc.Data[c] * flow.Fraction[c]
+		if r2 := flow.Receiver2[c]; r2 >= 0 {
+			acc.Data[r2] += acc.Data[c] * (1 - flow.Fraction[c])
+		}
+	}
+	acc.MinValue, acc.MaxValue = acc.Range()
+	flow.Accumulation = acc
+	fmt.Printf("  ├─ Acumulación de flujo: %.3f ms\n", float64(time.Since(startAccumulation).Microseconds())/1000)
+
+	fmt.Printf("  ├─ Estadísticas:\n")
+	fmt.Printf("  │  ├─ Celdas sin salida (sumideros y desagües): %d\n", pits)
+	fmt.Printf("  │  └─ Acumulación máxima: %.0f celdas\n", acc.MaxValue)
+	fmt.Printf("  └─ Tiempo total de cálculo de flujo: %.3f ms\n", float64(time.Since(startTotal).Microseconds())/1000)
+
+	return flow
+}
+
+// computeDInfinity fills the receivers, fractions and angles of flow with Tarboton's
+// D-infinity method: the steepest downhill facet gives a direction that is split between
+// its two corner neighbours in proportion to how close it is to each.
+func computeDInfinity(heightmap *Heightmap, flow *FlowField) {
+	width := flow.Width
+	height := flow.Height
+	cs := heightmap.CellSize
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			e0 := heightmap.At(x, y)
+
+			best, bestFacet, bestR := 0.0, -1, 0.0
+			for k, f := range dInfFacets {
+				x1, y1 := x+f.cardinal[0], y+f.cardinal[1]
+				x2, y2 := x+f.diagonal[0], y+f.diagonal[1]
+				if x1 < 0 || x1 >= width || y1 < 0 || y1 >= height || x2 < 0 || x2 >= width || y2 < 0 || y2 >= height {
+					continue
+				}
+				e1 := heightmap.At(x1, y1)
+				e2 := heightmap.At(x2, y2)
+
+				s1 := (e0 - e1) / cs
+				s2 := (e1 - e2) / cs
+				r := math.Atan2(s2, s1)
+				s := math.Hypot(s1, s2)
+				if r < 0 {
+					r, s = 0, s1
+				} else if r > math.Pi/4 {
+					r, s = math.Pi/4, (e0-e2)/(cs*math.Sqrt2)
+				}
+				if s > best {
+					best, bestFacet, bestR = s, k, r
+				}
+			}
+
+			flow.Receiver[i] = i
+			flow.Receiver2[i] = -1
+			flow.Fraction[i] = 1
+			flow.Angle[i] = math.NaN()
+			if bestFacet < 0 {
+				continue
+			}
+
+			f := dInfFacets[bestFacet]
+			cardinal := (y+f.cardinal[1])*width + x + f.cardinal[0]
+			diagonal := (y+f.diagonal[1])*width + x + f.diagonal[0]
+			toDiagonal := bestR / (math.Pi / 4)
+			switch {
+			case toDiagonal == 0:
+				flow.Receiver[i] = cardinal
+			case toDiagonal == 1:
+				flow.Receiver[i] = diagonal
+			case toDiagonal < 0.5:
+				flow.Receiver[i], flow.Receiver2[i], flow.Fraction[i] = cardinal, diagonal, 1-toDiagonal
+			default:
+				flow.Receiver[i], flow.Receiver2[i], flow.Fraction[i] = diagonal, cardinal, toDiagonal
+			}
+
+			angle := math.Atan2(float64(f.cardinal[1]), float64(f.cardinal[0])) + f.side*bestR
+			flow.Angle[i] = math.Atan2(math.Sin(angle), math.Cos(angle))
+		}
+	}
+}
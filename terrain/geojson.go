@@ -0,0 +1,58 @@
+package terrain
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+)
+
+// geoJSONFeature es una entidad GeoJSON con su geometría y propiedades
+type geoJSONFeature struct {
+	Type       string          `json:"type"`
+	Geometry   geoJSONGeometry `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+}
+
+// geoJSONGeometry es una geometría GeoJSON (LineString, Polygon, ...)
+type geoJSONGeometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// newGeoJSONFeature crea una entidad con la geometría y propiedades indicadas
+func newGeoJSONFeature(geometry geoJSONGeometry, properties map[string]any) geoJSONFeature {
+	return geoJSONFeature{Type: "Feature", Geometry: geometry, Properties: properties}
+}
+
+// geoJSONLineString convierte una polilínea en coordenadas de celda a unidades del mapa
+// (x hacia la derecha, y hacia abajo como en las imágenes)
+func geoJSONLineString(points [][2]float64, cellSize float64) geoJSONGeometry {
+	coords := make([][2]float64, len(points))
+	for i, p := range points {
+		coords[i] = [2]float64{p[0] * cellSize, p[1] * cellSize}
+	}
+	return geoJSONGeometry{Type: "LineString", Coordinates: coords}
+}
+
+// writeGeoJSON guarda las entidades como una FeatureCollection
+func writeGeoJSON(filename string, features []geoJSONFeature) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+
+	collection := struct {
+		Type     string           `json:"type"`
+		Features []geoJSONFeature `json:"features"`
+	}{"FeatureCollection", features}
+	if collection.Features == nil {
+		collection.Features = []geoJSONFeature{}
+	}
+
+	if err := json.NewEncoder(writer).Encode(collection); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,253 @@
+package terrain
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"time"
+)
+
+// GLTFOptions configura la exportación glTF 2.0
+type GLTFOptions struct {
+	// Textura de color opcional (por ejemplo BakeColorTexture o el mapa de biomas), aplicada
+	// con las UV planas de la malla. glTF multiplica COLOR_0 por la textura, así que cuando
+	// hay textura los colores de los vértices no se incluyen.
+	Texture image.Image
+}
+
+// Constantes de glTF 2.0
+const (
+	gltfFloat         = 5126
+	gltfUnsignedByte  = 5121
+	gltfUnsignedInt   = 5125
+	gltfArrayBuffer   = 34962
+	gltfElementBuffer = 34963
+	gltfTriangles     = 4
+	gltfLinear        = 9729
+	gltfLinearMipmap  = 9987
+	gltfClampToEdge   = 33071
+	glbMagic          = 0x46546C67 // "glTF"
+	glbChunkJSON      = 0x4E4F534A // "JSON"
+	glbChunkBIN       = 0x004E4942 // "BIN\0"
+)
+
+// gltfDocument contiene las partes del JSON de glTF que usa el exportador
+type gltfDocument struct {
+	Asset       map[string]string `json:"asset"`
+	Scene       int               `json:"scene"`
+	Scenes      []map[string]any  `json:"scenes"`
+	Nodes       []map[string]any  `json:"nodes"`
+	Meshes      []map[string]any  `json:"meshes"`
+	Materials   []map[string]any  `json:"materials"`
+	Textures    []map[string]any  `json:"textures,omitempty"`
+	Images      []map[string]any  `json:"images,omitempty"`
+	Samplers    []map[string]any  `json:"samplers,omitempty"`
+	Accessors   []map[string]any  `json:"accessors"`
+	BufferViews []map[string]any  `json:"bufferViews"`
+	Buffers     []map[string]any  `json:"buffers"`
+}
+
+// buildGLTF genera el documento glTF y su buffer binario: posiciones (Y arriba), normales,
+// UV, colores o textura, e índices de 32 bits
+func buildGLTF(vertices [][3]float64, faces [][3]int, colors [][3]float64, options GLTFOptions) (*gltfDocument, []byte, error) {
+	if len(vertices) == 0 || len(faces) == 0 {
+		return nil, nil, fmt.Errorf("la malla está vacía")
+	}
+	if uint64(len(vertices)) > math.MaxUint32 {
+		return nil, nil, fmt.Errorf("demasiados vértices para índices de 32 bits: %d", len(vertices))
+	}
+
+	uvs := planarUVs(vertices)
+	positions, faces := uprightMesh(vertices, faces, true)
+	normals := vertexNormals(positions, faces)
+
+	doc := &gltfDocument{
+		Asset:  map[string]string{"version": "2.0", "generator": "SimpleNoiseGenerator"},
+		Scenes: []map[string]any{{"nodes": []int{0}}},
+		Nodes:  []map[string]any{{"mesh": 0, "name": "Terreno"}},
+	}
+	var bin []byte
+
+	// addView añade datos al buffer, alineados a 4 bytes, y devuelve el índice de su bufferView
+	addView := func(data []byte, target int) int {
+		for len(bin)%4 != 0 {
+			bin = append(bin, 0)
+		}
+		view := map[string]any{"buffer": 0, "byteOffset": len(bin), "byteLength": len(data)}
+		if target != 0 {
+			view["target"] = target
+		}
+		bin = append(bin, data...)
+		doc.BufferViews = append(doc.BufferViews, view)
+		return len(doc.BufferViews) - 1
+	}
+	addAccessor := func(view, componentType, count int, kind string, extra map[string]any) int {
+		accessor := map[string]any{"bufferView": view, "componentType": componentType, "count": count, "type": kind}
+		for k, v := range extra {
+			accessor[k] = v
+		}
+		doc.Accessors = append(doc.Accessors, accessor)
+		return len(doc.Accessors) - 1
+	}
+	floats := func(n int, value func(i int) []float64) []byte {
+		data := make([]byte, 0, n*12)
+		for i := 0; i < n; i++ {
+			for _, f := range value(i) {
+				data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(f)))
+			}
+		}
+		return data
+	}
+
+	// POSITION exige los límites del accesor
+	minPos := [3]float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	maxPos := [3]float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, p := range positions {
+		for k := 0; k < 3; k++ {
+			minPos[k] = math.Min(minPos[k], float64(float32(p[k])))
+			maxPos[k] = math.Max(maxPos[k], float64(float32(p[k])))
+		}
+	}
+	attributes := map[string]int{}
+	attributes["POSITION"] = addAccessor(
+		addView(floats(len(positions), func(i int) []float64 { return positions[i][:] }), gltfArrayBuffer),
+		gltfFloat, len(positions), "VEC3", map[string]any{"min": minPos[:], "max": maxPos[:]})
+	attributes["NORMAL"] = addAccessor(
+		addView(floats(len(normals), func(i int) []float64 { return normals[i][:] }), gltfArrayBuffer),
+		gltfFloat, len(normals), "VEC3", nil)
+	attributes["TEXCOORD_0"] = addAccessor(
+		addView(floats(len(uvs), func(i int) []float64 { return uvs[i][:] }), gltfArrayBuffer),
+		gltfFloat, len(uvs), "VEC2", nil)
+
+	material := map[string]any{
+		"name":                 "Terreno",
+		"pbrMetallicRoughness": map[string]any{"metallicFactor": 0.0, "roughnessFactor": 1.0},
+	}
+	if options.Texture != nil {
+		var encoded bytes.Buffer
+		if err := png.Encode(&encoded, options.Texture); err != nil {
+			return nil, nil, err
+		}
+		doc.Images = []map[string]any{{"bufferView": addView(encoded.Bytes(), 0), "mimeType": "image/png"}}
+		doc.Samplers = []map[string]any{{
+			"magFilter": gltfLinear, "minFilter": gltfLinearMipmap, "wrapS": gltfClampToEdge, "wrapT": gltfClampToEdge,
+		}}
+		doc.Textures = []map[string]any{{"source": 0, "sampler": 0}}
+		material["pbrMetallicRoughness"].(map[string]any)["baseColorTexture"] = map[string]any{"index": 0}
+	} else if colors != nil {
+		// Colores RGBA de 8 bits normalizados
+		data := make([]byte, 0, len(colors)*4)
+		for _, c := range colors {
+			data = append(data, colorByte(c[0]), colorByte(c[1]), colorByte(c[2]), 255)
+		}
+		attributes["COLOR_0"] = addAccessor(addView(data, gltfArrayBuffer),
+			gltfUnsignedByte, len(colors), "VEC4", map[string]any{"normalized": true})
+	}
+	doc.Materials = []map[string]any{material}
+
+	indices := make([]byte, 0, len(faces)*12)
+	for _, f := range faces {
+		for _, i := range f {
+			indices = binary.LittleEndian.AppendUint32(indices, uint32(i))
+		}
+	}
+	indexAccessor := addAccessor(addView(indices, gltfElementBuffer), gltfUnsignedInt, len(faces)*3, "SCALAR", nil)
+
+	doc.Meshes = []map[string]any{{
+		"name": "Terreno",
+		"primitives": []map[string]any{{
+			"attributes": attributes,
+			"indices":    indexAccessor,
+			"material":   0,
+			"mode":       gltfTriangles,
+		}},
+	}}
+	for len(bin)%4 != 0 {
+		bin = append(bin, 0)
+	}
+	doc.Buffers = []map[string]any{{"byteLength": len(bin)}}
+	return doc, bin, nil
+}
+
+// SaveGLTF guarda la malla en formato glTF 2.0 (.gltf) con el buffer incrustado en base64.
+// El eje Y es la altura.
+func SaveGLTF(filename string, vertices [][3]float64, faces [][3]int, colors [][3]float64, options GLTFOptions) error {
+	startTotal := time.Now()
+	fmt.Printf("Guardando malla en archivo glTF: %s\n", filename)
+
+	doc, bin, err := buildGLTF(vertices, faces, colors, options)
+	if err != nil {
+		return err
+	}
+	doc.Buffers[0]["uri"] = "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(bin)
+	fmt.Printf("  ├─ Generación del buffer (%d vértices, %d caras): %.3f ms\n",
+		len(vertices), len(faces), float64(time.Since(startTotal).Microseconds())/1000)
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	if err := json.NewEncoder(writer).Encode(doc); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("  └─ Tiempo total guardado glTF: %.3f ms\n",
+		float64(time.Since(startTotal).Microseconds())/1000)
+
+	return file.Close()
+}
+
+// SaveGLB guarda la malla en formato glTF 2.0 binario (.glb): JSON y buffer en un único
+// archivo. El eje Y es la altura.
+func SaveGLB(filename string, vertices [][3]float64, faces [][3]int, colors [][3]float64, options GLTFOptions) error {
+	startTotal := time.Now()
+	fmt.Printf("Guardando malla en archivo GLB: %s\n", filename)
+
+	doc, bin, err := buildGLTF(vertices, faces, colors, options)
+	if err != nil {
+		return err
+	}
+	jsonChunk, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	// Los bloques se alinean a 4 bytes: el JSON con espacios y el binario con ceros
+	for len(jsonChunk)%4 != 0 {
+		jsonChunk = append(jsonChunk, ' ')
+	}
+	fmt.Printf("  ├─ Generación del buffer (%d vértices, %d caras): %.3f ms\n",
+		len(vertices), len(faces), float64(time.Since(startTotal).Microseconds())/1000)
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+
+	total := 12 + 8 + len(jsonChunk) + 8 + len(bin)
+	header := []uint32{glbMagic, 2, uint32(total), uint32(len(jsonChunk)), glbChunkJSON}
+	binary.Write(writer, binary.LittleEndian, header)
+	writer.Write(jsonChunk)
+	binary.Write(writer, binary.LittleEndian, []uint32{uint32(len(bin)), glbChunkBIN})
+	writer.Write(bin)
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("  ├─ Tamaño del archivo: %.2f MB\n", float64(total)/(1024*1024))
+	fmt.Printf("  └─ Tiempo total guardado GLB: %.3f ms\n",
+		float64(time.Since(startTotal).Microseconds())/1000)
+
+	return file.Close()
+}
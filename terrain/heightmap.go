@@ -0,0 +1,172 @@
+package terrain
+
+import (
+	"math"
+)
+
+// Heightmap is a grid of height samples stored row by row in one contiguous slice.
+// Sub-region views share Data with their parent and use Stride to skip between rows.
+type Heightmap struct {
+	Width    int       // Samples per row
+	Height   int       // Number of rows
+	Stride   int       // Offset in Data between the starts of consecutive rows
+	Data     []float64 // Row-major samples
+	CellSize float64   // Horizontal distance between neighbouring samples
+	Units    string    // Vertical units of the samples (e.g. "m"); empty for raw noise
+	MinValue float64   // Nominal lower bound of the values (e.g. -1 for raw noise)
+	MaxValue float64   // Nominal upper bound of the values
+}
+
+// NewHeightmap allocates a zeroed width x height heightmap with unit cells and the
+// [-1, 1] value range produced by the noise generators.
+func NewHeightmap(width, height int) *Heightmap {
+	return &Heightmap{
+		Width:    width,
+		Height:   height,
+		Stride:   width,
+		Data:     make([]float64, width*height),
+		CellSize: 1.0,
+		MinValue: -1.0,
+		MaxValue: 1.0,
+	}
+}
+
+// HeightmapFromRows copies a jagged [][]float64 grid into a new heightmap.
+func HeightmapFromRows(rows [][]float64) *Heightmap {
+	if len(rows) == 0 {
+		return NewHeightmap(0, 0)
+	}
+	hm := NewHeightmap(len(rows[0]), len(rows))
+	for y := range rows {
+		copy(hm.Row(y), rows[y])
+	}
+	return hm
+}
+
+// Index returns the position of sample (x, y) in Data.
+func (hm *Heightmap) Index(x, y int) int {
+	return y*hm.Stride + x
+}
+
+// At returns the sample at integer coordinates (x, y).
+func (hm *Heightmap) At(x, y int) float64 {
+	return hm.Data[y*hm.Stride+x]
+}
+
+// Set stores v at integer coordinates (x, y).
+func (hm *Heightmap) Set(x, y int, v float64) {
+	hm.Data[y*hm.Stride+x] = v
+}
+
+// Row returns row y as a slice sharing storage with the heightmap.
+func (hm *Heightmap) Row(y int) []float64 {
+	start := y * hm.Stride
+	return hm.Data[start : start+hm.Width : start+hm.Width]
+}
+
+// Sample finds the height at any continuous point using bilinear interpolation,
+// clamping coordinates to the edges of the map.
+func (hm *Heightmap) Sample(x, y float64) float64 {
+	// Convert floating-point coordinates to integer and fractional parts
+	ix := int(x)
+	iy := int(y)
+	fx := x - float64(ix) // Fractional part of x
+	fy := y - float64(iy) // Fractional part of y
+
+	// Ensure coordinates stay within bounds
+	ix0 := max(0, min(ix, hm.Width-1))
+	ix1 := max(0, min(ix+1, hm.Width-1))
+	iy0 := max(0, min(iy, hm.Height-1))
+	iy1 := max(0, min(iy+1, hm.Height-1))
+
+	// Get heights at the four surrounding grid points
+	h00 := hm.At(ix0, iy0) // Top-left
+	h10 := hm.At(ix1, iy0) // Top-right
+	h01 := hm.At(ix0, iy1) // Bottom-left
+	h11 := hm.At(ix1, iy1) // Bottom-right
+
+	// Perform bilinear interpolation
+	h0 := h00*(1-fx) + h10*fx // Interpolate along top edge
+	h1 := h01*(1-fx) + h11*fx // Interpolate along bottom edge
+	return h0*(1-fy) + h1*fy  // Interpolate between top and bottom
+}
+
+// Copy returns a deep, contiguous copy of the heightmap (views are compacted).
+func (hm *Heightmap) Copy() *Heightmap {
+	c := *hm
+	c.Stride = hm.Width
+	c.Data = make([]float64, hm.Width*hm.Height)
+	for y := 0; y < hm.Height; y++ {
+		copy(c.Data[y*c.Stride:(y+1)*c.Stride], hm.Row(y))
+	}
+	return &c
+}
+
+// SubRegion returns a w x h view starting at (x, y) that shares storage with hm.
+// The rectangle is clipped to the bounds of the heightmap.
+func (hm *Heightmap) SubRegion(x, y, w, h int) *Heightmap {
+	x = max(0, min(x, hm.Width))
+	y = max(0, min(y, hm.Height))
+	w = max(0, min(w, hm.Width-x))
+	h = max(0, min(h, hm.Height-y))
+
+	view := *hm
+	view.Width = w
+	view.Height = h
+	if w == 0 || h == 0 {
+		view.Data = nil
+		return &view
+	}
+	start := y*hm.Stride + x
+	view.Data = hm.Data[start : start+(h-1)*hm.Stride+w]
+	return &view
+}
+
+// Range returns the smallest and largest sample actually stored in the heightmap.
+func (hm *Heightmap) Range() (float64, float64) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for y := 0; y < hm.Height; y++ {
+		for _, v := range hm.Row(y) {
+			lo = math.Min(lo, v)
+			hi = math.Max(hi, v)
+		}
+	}
+	return lo, hi
+}
+
+// Apply replaces every sample v with f(v) in place.
+func (hm *Heightmap) Apply(f func(float64) float64) {
+	for y := 0; y < hm.Height; y++ {
+		row := hm.Row(y)
+		for x := range row {
+			row[x] = f(row[x])
+		}
+	}
+}
+
+// Remap returns a copy whose nominal [MinValue, MaxValue] range is linearly mapped
+// onto [newMin, newMax], e.g. raw noise in [-1, 1] to metres in [0, 256].
+func (hm *Heightmap) Remap(newMin, newMax float64, units string) *Heightmap {
+	c := hm.Copy()
+	span := hm.MaxValue - hm.MinValue
+	if span == 0 {
+		span = 1
+	}
+	k := (newMax - newMin) / span
+	for i, v := range c.Data {
+		c.Data[i] = (v-hm.MinValue)*k + newMin
+	}
+	c.MinValue = newMin
+	c.MaxValue = newMax
+	c.Units = units
+	return c
+}
+
+// Rows returns the samples as a freshly allocated jagged [][]float64 grid.
+func (hm *Heightmap) Rows() [][]float64 {
+	rows := make([][]float64, hm.Height)
+	for y := range rows {
+		rows[y] = append([]float64(nil), hm.Row(y)...)
+	}
+	return rows
+}
@@ -0,0 +1,352 @@
+package terrain
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+)
+
+// HeightRange es el intervalo de alturas que se lleva al rango completo de un formato
+// entero (Min → 0, Max → 65535). El valor cero usa al escribir el rango nominal del
+// heightmap (MinValue, MaxValue) y al leer el [-1, 1] de los mapas de ruido.
+type HeightRange struct {
+	Min float64
+	Max float64
+}
+
+// isZero indica si el rango no se ha configurado
+func (r HeightRange) isZero() bool {
+	return r.Min == 0 && r.Max == 0
+}
+
+// resolve devuelve el rango a usar (def si no se ha configurado) con un intervalo no vacío
+func (r HeightRange) resolve(def HeightRange) HeightRange {
+	if r.isZero() {
+		r = def
+	}
+	if r.Max == r.Min {
+		r.Max = r.Min + 1
+	}
+	return r
+}
+
+// toUint16 normaliza una altura al rango [0, 65535], recortando fuera del intervalo
+func (r HeightRange) toUint16(v float64) uint16 {
+	t := (v - r.Min) / (r.Max - r.Min)
+	return uint16(math.Round(math.Max(0, math.Min(1, t)) * math.MaxUint16))
+}
+
+// fromUint16 deshace la normalización de toUint16
+func (r HeightRange) fromUint16(v uint16) float64 {
+	return r.Min + float64(v)/math.MaxUint16*(r.Max-r.Min)
+}
+
+// newHeightmapInRange crea un heightmap vacío cuyo rango nominal es r
+func newHeightmapInRange(width, height int, r HeightRange) *Heightmap {
+	hm := NewHeightmap(width, height)
+	hm.MinValue = r.Min
+	hm.MaxValue = r.Max
+	return hm
+}
+
+// SaveHeightmapPNG16 guarda el heightmap como PNG en escala de grises de 16 bits,
+// normalizado con el rango indicado
+func SaveHeightmapPNG16(filename string, heightmap *Heightmap, r HeightRange) error {
+	r = r.resolve(HeightRange{heightmap.MinValue, heightmap.MaxValue})
+	img := image.NewGray16(image.Rect(0, 0, heightmap.Width, heightmap.Height))
+	for y := 0; y < heightmap.Height; y++ {
+		for x, v := range heightmap.Row(y) {
+			img.SetGray16(x, y, color.Gray16{Y: r.toUint16(v)})
+		}
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return png.Encode(file, img)
+}
+
+// LoadHeightmapPNG16 lee un PNG en escala de grises (de 16 bits o convertible) y devuelve
+// las alturas en el rango indicado; con el mismo rango que al guardar recupera los datos
+func LoadHeightmapPNG16(filename string, r HeightRange) (*Heightmap, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, err := png.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+	r = r.resolve(HeightRange{-1, 1})
+
+	bounds := img.Bounds()
+	heightmap := newHeightmapInRange(bounds.Dx(), bounds.Dy(), r)
+	for y := 0; y < heightmap.Height; y++ {
+		row := heightmap.Row(y)
+		for x := range row {
+			g := color.Gray16Model.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray16)
+			row[x] = r.fromUint16(g.Y)
+		}
+	}
+	return heightmap, nil
+}
+
+// SaveHeightmapR16 guarda el heightmap como RAW de 16 bits sin cabecera (little endian,
+// fila a fila), el formato de importación de Unity, Unreal y World Machine
+func SaveHeightmapR16(filename string, heightmap *Heightmap, r HeightRange) error {
+	r = r.resolve(HeightRange{heightmap.MinValue, heightmap.MaxValue})
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+
+	row := make([]byte, 2*heightmap.Width)
+	for y := 0; y < heightmap.Height; y++ {
+		for x, v := range heightmap.Row(y) {
+			binary.LittleEndian.PutUint16(row[2*x:], r.toUint16(v))
+		}
+		if _, err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// LoadHeightmapR16 lee un RAW de 16 bits little endian de width x height muestras.
+// Con width y height a 0 se asume un mapa cuadrado deducido del tamaño del archivo.
+func LoadHeightmapR16(filename string, width, height int, r HeightRange) (*Heightmap, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	samples := len(data) / 2
+	if width == 0 && height == 0 {
+		side := int(math.Round(math.Sqrt(float64(samples))))
+		width, height = side, side
+	}
+	if width*height != samples || len(data)%2 != 0 {
+		return nil, fmt.Errorf("%s: %d bytes no corresponden a %dx%d muestras de 16 bits", filename, len(data), width, height)
+	}
+	r = r.resolve(HeightRange{-1, 1})
+
+	heightmap := newHeightmapInRange(width, height, r)
+	for i := range heightmap.Data {
+		heightmap.Data[i] = r.fromUint16(binary.LittleEndian.Uint16(data[2*i:]))
+	}
+	return heightmap, nil
+}
+
+// Etiquetas TIFF usadas por el codificador y el lector
+const (
+	tiffImageWidth          = 256
+	tiffImageLength         = 257
+	tiffBitsPerSample       = 258
+	tiffCompression         = 259
+	tiffPhotometric         = 262
+	tiffStripOffsets        = 273
+	tiffSamplesPerPixel     = 277
+	tiffRowsPerStrip        = 278
+	tiffStripByteCounts     = 279
+	tiffPlanarConfiguration = 284
+	tiffSampleFormat        = 339
+
+	tiffShort = 3
+	tiffLong  = 4
+)
+
+// SaveHeightmapTIFF guarda el heightmap como TIFF de coma flotante de 32 bits sin comprimir.
+// Con el rango a cero se guardan las alturas tal cual; si no, normalizadas a [0, 1].
+func SaveHeightmapTIFF(filename string, heightmap *Heightmap, r HeightRange) error {
+	normalize := !r.isZero()
+	if normalize {
+		r = r.resolve(HeightRange{heightmap.MinValue, heightmap.MaxValue})
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+
+	// Cabecera, un único IFD y los datos en una sola tira a continuación
+	type entry struct {
+		tag, kind uint16
+		value     uint32
+	}
+	width, height := heightmap.Width, heightmap.Height
+	entries := []entry{
+		{tiffImageWidth, tiffLong, uint32(width)},
+		{tiffImageLength, tiffLong, uint32(height)},
+		{tiffBitsPerSample, tiffShort, 32},
+		{tiffCompression, tiffShort, 1},
+		{tiffPhotometric, tiffShort, 1}, // Negro es cero
+		{tiffStripOffsets, tiffLong, 0}, // Se completa abajo
+		{tiffSamplesPerPixel, tiffShort, 1},
+		{tiffRowsPerStrip, tiffLong, uint32(height)},
+		{tiffStripByteCounts, tiffLong, uint32(4 * width * height)},
+		{tiffPlanarConfiguration, tiffShort, 1},
+		{tiffSampleFormat, tiffShort, 3}, // IEEE float
+	}
+	ifdSize := 2 + 12*len(entries) + 4
+	entries[5].value = uint32(8 + ifdSize)
+
+	le := binary.LittleEndian
+	header := []byte{'I', 'I', 42, 0, 8, 0, 0, 0}
+	ifd := make([]byte, ifdSize)
+	le.PutUint16(ifd, uint16(len(entries)))
+	for k, e := range entries {
+		b := ifd[2+12*k:]
+		le.PutUint16(b, e.tag)
+		le.PutUint16(b[2:], e.kind)
+		le.PutUint32(b[4:], 1)
+		if e.kind == tiffShort {
+			le.PutUint16(b[8:], uint16(e.value))
+		} else {
+			le.PutUint32(b[8:], e.value)
+		}
+	}
+	if _, err := writer.Write(append(header, ifd...)); err != nil {
+		return err
+	}
+
+	row := make([]byte, 4*width)
+	for y := 0; y < height; y++ {
+		for x, v := range heightmap.Row(y) {
+			if normalize {
+				v = (v - r.Min) / (r.Max - r.Min)
+			}
+			le.PutUint32(row[4*x:], math.Float32bits(float32(v)))
+		}
+		if _, err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// LoadHeightmapTIFF lee un TIFF sin comprimir de una muestra por píxel en coma flotante
+// de 32 bits (little o big endian, con una o varias tiras). Con un rango distinto de cero
+// los valores se desnormalizan desde [0, 1]; si no, el rango nominal es el observado.
+func LoadHeightmapTIFF(filename string, r HeightRange) (*Heightmap, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	fail := func(format string, args ...any) (*Heightmap, error) {
+		return nil, fmt.Errorf("%s: "+format, append([]any{filename}, args...)...)
+	}
+
+	if len(data) < 8 {
+		return fail("archivo TIFF demasiado corto")
+	}
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return fail("no es un archivo TIFF")
+	}
+	if order.Uint16(data[2:]) != 42 {
+		return fail("no es un archivo TIFF")
+	}
+
+	// Leer las entradas del primer IFD
+	offset := int(order.Uint32(data[4:]))
+	if offset+2 > len(data) {
+		return fail("IFD fuera del archivo")
+	}
+	count := int(order.Uint16(data[offset:]))
+	if offset+2+12*count > len(data) {
+		return fail("IFD fuera del archivo")
+	}
+	tags := make(map[uint16][]uint32)
+	for k := 0; k < count; k++ {
+		b := data[offset+2+12*k:]
+		tag, kind, n := order.Uint16(b), order.Uint16(b[2:]), int(order.Uint32(b[4:]))
+		size := 2
+		if kind == tiffLong {
+			size = 4
+		} else if kind != tiffShort {
+			continue // Solo interesan etiquetas enteras
+		}
+		values := b[8:12]
+		if n*size > 4 {
+			start := int(order.Uint32(b[8:]))
+			if start+n*size > len(data) {
+				return fail("valores de la etiqueta %d fuera del archivo", tag)
+			}
+			values = data[start : start+n*size]
+		}
+		for j := 0; j < n; j++ {
+			if size == 2 {
+				tags[tag] = append(tags[tag], uint32(order.Uint16(values[2*j:])))
+			} else {
+				tags[tag] = append(tags[tag], order.Uint32(values[4*j:]))
+			}
+		}
+	}
+	get := func(tag uint16, def uint32) uint32 {
+		if v := tags[tag]; len(v) > 0 {
+			return v[0]
+		}
+		return def
+	}
+
+	width, height := int(get(tiffImageWidth, 0)), int(get(tiffImageLength, 0))
+	switch {
+	case width == 0 || height == 0:
+		return fail("dimensiones no válidas")
+	case get(tiffCompression, 1) != 1:
+		return fail("compresión no soportada")
+	case get(tiffSamplesPerPixel, 1) != 1:
+		return fail("se esperaba una muestra por píxel")
+	case get(tiffBitsPerSample, 1) != 32 || get(tiffSampleFormat, 1) != 3:
+		return fail("se esperaban muestras de coma flotante de 32 bits")
+	}
+
+	// Concatenar las tiras en orden
+	pixels := make([]byte, 0, 4*width*height)
+	offsets, counts := tags[tiffStripOffsets], tags[tiffStripByteCounts]
+	if len(offsets) == 0 || len(offsets) != len(counts) {
+		return fail("tiras de datos no válidas")
+	}
+	for k := range offsets {
+		start, end := int(offsets[k]), int(offsets[k])+int(counts[k])
+		if end > len(data) {
+			return fail("tira %d fuera del archivo", k)
+		}
+		pixels = append(pixels, data[start:end]...)
+	}
+	if len(pixels) < 4*width*height {
+		return fail("datos incompletos: %d de %d bytes", len(pixels), 4*width*height)
+	}
+
+	heightmap := NewHeightmap(width, height)
+	for i := range heightmap.Data {
+		heightmap.Data[i] = float64(math.Float32frombits(order.Uint32(pixels[4*i:])))
+	}
+	if r.isZero() {
+		heightmap.MinValue, heightmap.MaxValue = heightmap.Range()
+	} else {
+		r = r.resolve(HeightRange{heightmap.MinValue, heightmap.MaxValue})
+		for i, v := range heightmap.Data {
+			heightmap.Data[i] = r.Min + v*(r.Max-r.Min)
+		}
+		heightmap.MinValue, heightmap.MaxValue = r.Min, r.Max
+	}
+	return heightmap, nil
+}
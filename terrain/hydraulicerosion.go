@@ -8,37 +8,12 @@ import (
 )
 
 // InterpolateHeight finds the terrain height at any continuous point using bilinear interpolation
-func InterpolateHeight(heightmap [][]float64, x, y float64) float64 {
-	// Convert floating-point coordinates to integer and fractional parts
-	ix := int(x)
-	iy := int(y)
-	fx := x - float64(ix) // Fractional part of x
-	fy := y - float64(iy) // Fractional part of y
-
-	// Get height and width of the heightmap
-	height := len(heightmap)
-	width := len(heightmap[0])
-
-	// Ensure coordinates stay within bounds
-	ix0 := max(0, min(ix, width-1))
-	ix1 := max(0, min(ix+1, width-1))
-	iy0 := max(0, min(iy, height-1))
-	iy1 := max(0, min(iy+1, height-1))
-
-	// Get heights at the four surrounding grid points
-	h00 := heightmap[iy0][ix0] // Top-left
-	h10 := heightmap[iy0][ix1] // Top-right
-	h01 := heightmap[iy1][ix0] // Bottom-left
-	h11 := heightmap[iy1][ix1] // Bottom-right
-
-	// Perform bilinear interpolation
-	h0 := h00*(1-fx) + h10*fx // Interpolate along top edge
-	h1 := h01*(1-fx) + h11*fx // Interpolate along bottom edge
-	return h0*(1-fy) + h1*fy  // Interpolate between top and bottom
+func InterpolateHeight(heightmap *Heightmap, x, y float64) float64 {
+	return heightmap.Sample(x, y)
 }
 
 // ComputeGradient calculates the slope direction at a specific point
-func ComputeGradient(heightmap [][]float64, x, y float64, epsilon float64) (float64, float64) {
+func ComputeGradient(heightmap *Heightmap, x, y float64, epsilon float64) (float64, float64) {
 	// Use central difference method to estimate partial derivatives
 	gx := (InterpolateHeight(heightmap, x+epsilon, y) - InterpolateHeight(heightmap, x-epsilon, y)) / (2 * epsilon)
 	gy := (InterpolateHeight(heightmap, x, y+epsilon) - InterpolateHeight(heightmap, x, y-epsilon)) / (2 * epsilon)
@@ -47,244 +22,367 @@ func ComputeGradient(heightmap [][]float64, x, y float64, epsilon float64) (floa
 
 // Parameters for erosion simulation
 type ErosionParams struct {
-	MaxSteps         int     // Maximum lifetime of each droplet
-	Inertia          float64 // How much a droplet maintains its direction
-	SedimentCapacity float64 // How much sediment a droplet can carry
-	ErosionRate      float64 // How quickly droplets pick up sediment
-	DepositionRate   float64 // How quickly droplets deposit sediment
-	EvaporationRate  float64 // How quickly water evaporates
-	Gravity          float64 // Affects droplet velocity
-	MinSlope         float64 // Minimum slope for movement
-	CellSize         float64 // Scale factor for movement distance
+	MaxSteps         int        // Maximum lifetime of each droplet
+	Inertia          float64    // How much a droplet maintains its direction
+	SedimentCapacity float64    // How much sediment a droplet can carry
+	ErosionRate      float64    // How quickly droplets pick up sediment
+	DepositionRate   float64    // How quickly droplets deposit sediment
+	EvaporationRate  float64    // How quickly water evaporates
+	Gravity          float64    // Affects droplet velocity
+	MinSlope         float64    // Minimum slope for movement
+	CellSize         float64    // Scale factor for movement distance
+	Seed             int64      // Seed for droplet start positions (same seed, same result)
+	Rand             *rand.Rand // Optional caller-supplied source; overrides Seed when set
+	Workers          int        // Goroutines for parallel tiled erosion (<= 1 runs sequentially)
+	TileSize         int        // Tile side in cells for parallel erosion (0 picks a default)
+	Radius           int        // Erosion brush radius in cells (0 erodes only the 4 cells under the droplet)
 }
 
-// ApplyErosion simulates hydraulic erosion by running multiple water droplets across the terrain
-func ApplyErosion(heightmap [][]float64, numDroplets int, params ErosionParams) [][]float64 {
+// ApplyErosion simulates hydraulic erosion by running multiple water droplets across the terrain.
+// With Workers > 1 the droplets are simulated in parallel tiles (see applyErosionParallel).
+func ApplyErosion(heightmap *Heightmap, numDroplets int, params ErosionParams) *Heightmap {
 	startTotal := time.Now()
 	fmt.Printf("Iniciando simulación de erosión hidráulica (%d gotas)...\n", numDroplets)
 
 	// Create a copy of the heightmap to avoid modifying the original
 	startCopy := time.Now()
-	height := len(heightmap)
-	width := len(heightmap[0])
-	result := make([][]float64, height)
-	for i := range result {
-		result[i] = make([]float64, width)
-		copy(result[i], heightmap[i])
-	}
+	height := heightmap.Height
+	width := heightmap.Width
+	result := heightmap.Copy()
 	fmt.Printf("  ├─ Copia del mapa: %.3f ms\n",
 		float64(time.Since(startCopy).Microseconds())/1000)
 
-	// Estadísticas
-	totalSteps := 0
-	maxSteps := 0
-	dropletsOffMap := 0
-	dropletsEvaporated := 0
-	dropletsNoDirection := 0
-	totalDeposited := 0.0
-	totalEroded := 0.0
+	// Fuente aleatoria determinista para las posiciones iniciales
+	rng := params.Rand
+	if rng == nil {
+		rng = rand.New(rand.NewSource(params.Seed))
+		fmt.Printf("  ├─ Semilla de erosión: %d\n", params.Seed)
+	}
+
+	// Precompute the erosion brush shared by every droplet
+	brush := newErosionBrush(params.Radius)
+	if brush != nil {
+		fmt.Printf("  ├─ Pincel de erosión: radio %d (%d celdas)\n", params.Radius, len(brush.weights))
+	}
 
 	// Simulate each water droplet
 	startDroplets := time.Now()
-	reportInterval := numDroplets / 10 // Reportar progreso cada 10%
-	if reportInterval < 1 {
-		reportInterval = 1
-	}
+	var stats erosionStats
+	if params.Workers > 1 {
+		stats = applyErosionParallel(result, numDroplets, params, brush, rng)
+	} else {
+		reportInterval := numDroplets / 10 // Reportar progreso cada 10%
+		if reportInterval < 1 {
+			reportInterval = 1
+		}
 
-	for d := 0; d < numDroplets; d++ {
-		if d > 0 && d%reportInterval == 0 {
-			pctComplete := float64(d) / float64(numDroplets) * 100
-			timeElapsed := time.Since(startDroplets)
-			timeEstimated := time.Duration(float64(timeElapsed) / (float64(d) / float64(numDroplets)))
-			timeRemaining := timeEstimated - timeElapsed
+		wholeMap := dropletRegion{0, 0, float64(width), float64(height)}
+		for d := 0; d < numDroplets; d++ {
+			if d > 0 && d%reportInterval == 0 {
+				pctComplete := float64(d) / float64(numDroplets) * 100
+				timeElapsed := time.Since(startDroplets)
+				timeEstimated := time.Duration(float64(timeElapsed) / (float64(d) / float64(numDroplets)))
+				timeRemaining := timeEstimated - timeElapsed
+
+				fmt.Printf("  │  ├─ %.1f%% completado - Tiempo restante: %.1f s (%.0f gotas/s)\n",
+					pctComplete, timeRemaining.Seconds(),
+					float64(d)/timeElapsed.Seconds())
+			}
 
-			fmt.Printf("  │  ├─ %.1f%% completado - Tiempo restante: %.1f s (%.0f gotas/s)\n",
-				pctComplete, timeRemaining.Seconds(),
-				float64(d)/timeElapsed.Seconds())
+			// Random starting position for the droplet
+			x := rng.Float64() * float64(width-1)
+			y := rng.Float64() * float64(height-1)
+			simulateDroplet(result, x, y, params, wholeMap, brush, &stats)
 		}
+	}
 
-		// Random starting position for the droplet
-		x := rand.Float64() * float64(width-1)
-		y := rand.Float64() * float64(height-1)
-
-		// Initial movement direction, velocity, water volume, and sediment
-		dirX, dirY := 0.0, 0.0
-		velocity := 0.0
-		water := 1.0
-		sediment := 0.0
-		steps := 0
-		dropletDeposited := 0.0
-		dropletEroded := 0.0
-
-		// Simulate each step of the droplet's lifetime
-		for step := 0; step < params.MaxSteps; step++ {
-			steps++
-
-			// Calculate gradient at current position
-			gx, gy := ComputeGradient(result, x, y, 1e-5)
-			slope := math.Sqrt(gx*gx + gy*gy)
-
-			// If slope is too shallow, water wouldn't flow
-			if slope < params.MinSlope {
-				gx, gy, slope = 0.0, 0.0, 0.0
-			}
+	simulationTime := time.Since(startDroplets)
+	fmt.Printf("  ├─ Simulación de erosión: %.3f s (%.0f gotas/s)\n",
+		simulationTime.Seconds(), float64(numDroplets)/simulationTime.Seconds())
 
-			// Calculate movement direction with inertia
-			dirX = dirX*params.Inertia + gx*(1-params.Inertia)
-			dirY = dirY*params.Inertia + gy*(1-params.Inertia)
-			dirLength := math.Hypot(dirX, dirY)
+	stats.print(numDroplets)
 
-			// If no direction, droplet stops moving
-			if dirLength == 0 {
-				dropletsNoDirection++
-				break
-			}
+	fmt.Printf("  └─ Tiempo total de erosión: %.3f s\n", time.Since(startTotal).Seconds())
+
+	return result
+}
+
+// erosionStats accumulates per-droplet statistics of a hydraulic erosion run
+type erosionStats struct {
+	totalSteps          int
+	maxSteps            int
+	dropletsOffMap      int
+	dropletsEvaporated  int
+	dropletsNoDirection int
+	dropletsOutOfRegion int // Parallel mode only: droplets stopped at the edge of their tile
+	totalDeposited      float64
+	totalEroded         float64
+}
 
-			// Normalize direction vector
-			dirX /= dirLength
-			dirY /= dirLength
+// merge adds the statistics of another run into s
+func (s *erosionStats) merge(o erosionStats) {
+	s.totalSteps += o.totalSteps
+	s.maxSteps = max(s.maxSteps, o.maxSteps)
+	s.dropletsOffMap += o.dropletsOffMap
+	s.dropletsEvaporated += o.dropletsEvaporated
+	s.dropletsNoDirection += o.dropletsNoDirection
+	s.dropletsOutOfRegion += o.dropletsOutOfRegion
+	s.totalDeposited += o.totalDeposited
+	s.totalEroded += o.totalEroded
+}
 
-			// Calculate new position
-			newX := x + dirX*params.CellSize
-			newY := y + dirY*params.CellSize
+// print shows the statistics in the same tree layout as the timing output
+func (s *erosionStats) print(numDroplets int) {
+	n := float64(max(numDroplets, 1))
+	fmt.Printf("  ├─ Estadísticas:\n")
+	fmt.Printf("  │  ├─ Pasos promedio por gota: %.1f (máx: %d)\n", float64(s.totalSteps)/n, s.maxSteps)
+	fmt.Printf("  │  ├─ Gotas evaporadas: %d (%.1f%%)\n", s.dropletsEvaporated, float64(s.dropletsEvaporated)/n*100)
+	fmt.Printf("  │  ├─ Gotas fuera del mapa: %d (%.1f%%)\n", s.dropletsOffMap, float64(s.dropletsOffMap)/n*100)
+	fmt.Printf("  │  ├─ Gotas sin dirección: %d (%.1f%%)\n", s.dropletsNoDirection, float64(s.dropletsNoDirection)/n*100)
+	if s.dropletsOutOfRegion > 0 {
+		fmt.Printf("  │  ├─ Gotas detenidas en el borde de su bloque: %d (%.1f%%)\n", s.dropletsOutOfRegion, float64(s.dropletsOutOfRegion)/n*100)
+	}
+	fmt.Printf("  │  ├─ Material erosionado: %.1f unidades\n", s.totalEroded)
+	fmt.Printf("  │  └─ Material depositado: %.1f unidades\n", s.totalDeposited)
+}
+
+// dropletRegion bounds the area a droplet may travel through; [x0, x1) x [y0, y1)
+type dropletRegion struct {
+	x0, y0, x1, y1 float64
+}
+
+// erosionBrush spreads eroded material over the cells within a radius of the droplet,
+// weighted by closeness (as in Lague's and Beyer's implementations)
+type erosionBrush struct {
+	offsetX, offsetY []int
+	weights          []float64
+	reach            int // Furthest cell touched, counted from the droplet's integer cell
+}
 
-			// Stop if droplet flows off the map
-			if newX < 0 || newX >= float64(width) || newY < 0 || newY >= float64(height) {
-				dropletsOffMap++
-				break
+// newErosionBrush precomputes the weights of a circular brush; nil for radius <= 0
+func newErosionBrush(radius int) *erosionBrush {
+	if radius <= 0 {
+		return nil
+	}
+	b := &erosionBrush{reach: radius}
+	r := float64(radius)
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			dist := math.Hypot(float64(dx), float64(dy))
+			if dist < r {
+				b.offsetX = append(b.offsetX, dx)
+				b.offsetY = append(b.offsetY, dy)
+				b.weights = append(b.weights, 1-dist/r)
 			}
+		}
+	}
+	return b
+}
+
+// erode removes up to amount around the cell nearest to (x, y) and returns the material
+// taken. Weights are renormalised over the cells inside the map.
+func (b *erosionBrush) erode(result *Heightmap, x, y, amount float64) float64 {
+	cx, cy := int(x+0.5), int(y+0.5)
+	totalWeight := 0.0
+	for k, w := range b.weights {
+		i, j := cx+b.offsetX[k], cy+b.offsetY[k]
+		if i >= 0 && i < result.Width && j >= 0 && j < result.Height {
+			totalWeight += w
+		}
+	}
+
+	taken := 0.0
+	for k, w := range b.weights {
+		i, j := cx+b.offsetX[k], cy+b.offsetY[k]
+		if i >= 0 && i < result.Width && j >= 0 && j < result.Height {
+			// Limit erosion to prevent negative heights
+			cell := &result.Data[j*result.Stride+i]
+			erode := math.Min(amount*w/totalWeight, *cell)
+			*cell -= erode
+			taken += erode
+		}
+	}
+	return taken
+}
+
+// simulateDroplet runs a single droplet from (x, y) until it stops, eroding and depositing on result.
+// A nil brush erodes with bilinear weights on the four cells under the droplet.
+func simulateDroplet(result *Heightmap, x, y float64, params ErosionParams, region dropletRegion, brush *erosionBrush, stats *erosionStats) {
+	height := result.Height
+	width := result.Width
+	stride := result.Stride
+
+	// Initial movement direction, velocity, water volume, and sediment
+	dirX, dirY := 0.0, 0.0
+	velocity := 0.0
+	water := 1.0
+	sediment := 0.0
+	steps := 0
+
+	// Simulate each step of the droplet's lifetime
+	for step := 0; step < params.MaxSteps; step++ {
+		steps++
+
+		// Calculate gradient at current position
+		gx, gy := ComputeGradient(result, x, y, 1e-5)
+		slope := math.Sqrt(gx*gx + gy*gy)
+
+		// If slope is too shallow, water wouldn't flow
+		if slope < params.MinSlope {
+			gx, gy, slope = 0.0, 0.0, 0.0
+		}
+
+		// Calculate movement direction with inertia; water flows down the gradient
+		dirX = dirX*params.Inertia - gx*(1-params.Inertia)
+		dirY = dirY*params.Inertia - gy*(1-params.Inertia)
+		dirLength := math.Hypot(dirX, dirY)
+
+		// If no direction, droplet stops moving
+		if dirLength == 0 {
+			stats.dropletsNoDirection++
+			break
+		}
+
+		// Normalize direction vector
+		dirX /= dirLength
+		dirY /= dirLength
 
-			// Calculate height difference between old and new position
-			oldHeight := InterpolateHeight(result, x, y)
-			newHeight := InterpolateHeight(result, newX, newY)
-			deltaH := newHeight - oldHeight
-
-			// Calculate sediment capacity based on slope and velocity
-			capacity := math.Max(-deltaH, 0.0) * velocity * params.SedimentCapacity
-			capacity = math.Max(capacity, params.MinSlope)
-
-			// Handle deposition (when carrying too much sediment or going uphill)
-			if sediment > capacity || deltaH > 0 {
-				depositAmount := math.Min((sediment-capacity)*params.DepositionRate, sediment)
-				sediment -= depositAmount
-				dropletDeposited += depositAmount
-				totalDeposited += depositAmount
-
-				ix, iy := int(x), int(y)
-				fx, fy := x-float64(ix), y-float64(iy)
-
-				// Distribute deposited sediment to surrounding cells
-				for di := 0; di <= 1; di++ {
-					for dj := 0; dj <= 1; dj++ {
-						// Calculate bilinear weight
-						wi := 0.0
-						if di == 0 {
-							wi = (1 - fx)
-						} else {
-							wi = fx
-						}
-
-						if dj == 0 {
-							wi *= (1 - fy)
-						} else {
-							wi *= fy
-						}
-
-						i, j := ix+di, iy+dj
-						if i >= 0 && i < width && j >= 0 && j < height {
-							result[j][i] += depositAmount * wi
-						}
+		// Calculate new position
+		newX := x + dirX*params.CellSize
+		newY := y + dirY*params.CellSize
+
+		// Stop if droplet flows off the map
+		if newX < 0 || newX >= float64(width) || newY < 0 || newY >= float64(height) {
+			stats.dropletsOffMap++
+			break
+		}
+
+		// Stop if droplet leaves the region it is allowed to modify
+		if newX < region.x0 || newX >= region.x1 || newY < region.y0 || newY >= region.y1 {
+			stats.dropletsOutOfRegion++
+			break
+		}
+
+		// Calculate height difference between old and new position
+		oldHeight := InterpolateHeight(result, x, y)
+		newHeight := InterpolateHeight(result, newX, newY)
+		deltaH := newHeight - oldHeight
+
+		// Calculate sediment capacity based on slope and velocity
+		capacity := math.Max(-deltaH, 0.0) * velocity * params.SedimentCapacity
+		capacity = math.Max(capacity, params.MinSlope)
+
+		// Handle deposition (when carrying too much sediment or going uphill)
+		if sediment > capacity || deltaH > 0 {
+			depositAmount := math.Min((sediment-capacity)*params.DepositionRate, sediment)
+			sediment -= depositAmount
+			stats.totalDeposited += depositAmount
+
+			ix, iy := int(x), int(y)
+			fx, fy := x-float64(ix), y-float64(iy)
+
+			// Distribute deposited sediment to surrounding cells
+			for di := 0; di <= 1; di++ {
+				for dj := 0; dj <= 1; dj++ {
+					// Calculate bilinear weight
+					wi := 0.0
+					if di == 0 {
+						wi = (1 - fx)
+					} else {
+						wi = fx
 					}
-				}
-			} else {
-				// Handle erosion (when carrying less than capacity and going downhill)
-				erosionAmount := math.Min((capacity-sediment)*params.ErosionRate, -deltaH)
-				erosionAmount = math.Max(erosionAmount, 0)
-
-				ix, iy := int(x), int(y)
-				fx, fy := x-float64(ix), y-float64(iy)
-				totalWeight := 0.0
-
-				// Erode from surrounding cells
-				for di := 0; di <= 1; di++ {
-					for dj := 0; dj <= 1; dj++ {
-						// Calculate bilinear weight
-						wi := 0.0
-						if di == 0 {
-							wi = (1 - fx)
-						} else {
-							wi = fx
-						}
-
-						if dj == 0 {
-							wi *= (1 - fy)
-						} else {
-							wi *= fy
-						}
-
-						i, j := ix+di, iy+dj
-						if i >= 0 && i < width && j >= 0 && j < height {
-							// Limit erosion to prevent negative heights
-							erode := math.Min(erosionAmount*wi, result[j][i])
-							result[j][i] -= erode
-							sediment += erode
-							totalWeight += wi
-							dropletEroded += erode
-							totalEroded += erode
-						}
+
+					if dj == 0 {
+						wi *= (1 - fy)
+					} else {
+						wi *= fy
 					}
-				}
 
-				// Account for potential cells outside the map
-				if totalWeight > 0 {
-					additionalSediment := erosionAmount * (1 - totalWeight)
-					sediment += additionalSediment
-					totalEroded += additionalSediment
+					i, j := ix+di, iy+dj
+					if i >= 0 && i < width && j >= 0 && j < height {
+						result.Data[j*stride+i] += depositAmount * wi
+					}
 				}
 			}
+		} else if brush != nil {
+			// Handle erosion, spread over the brush around the droplet
+			erosionAmount := math.Min((capacity-sediment)*params.ErosionRate, -deltaH)
+			erosionAmount = math.Max(erosionAmount, 0)
+
+			taken := brush.erode(result, x, y, erosionAmount)
+			sediment += taken
+			stats.totalEroded += taken
+		} else {
+			// Handle erosion (when carrying less than capacity and going downhill)
+			erosionAmount := math.Min((capacity-sediment)*params.ErosionRate, -deltaH)
+			erosionAmount = math.Max(erosionAmount, 0)
+
+			ix, iy := int(x), int(y)
+			fx, fy := x-float64(ix), y-float64(iy)
+			totalWeight := 0.0
+
+			// Erode from surrounding cells
+			for di := 0; di <= 1; di++ {
+				for dj := 0; dj <= 1; dj++ {
+					// Calculate bilinear weight
+					wi := 0.0
+					if di == 0 {
+						wi = (1 - fx)
+					} else {
+						wi = fx
+					}
 
-			// Update droplet properties
-			velocity = math.Sqrt(velocity*velocity + deltaH*params.Gravity)
-			velocity = math.Max(velocity, 0)
-			water *= (1 - params.EvaporationRate)
+					if dj == 0 {
+						wi *= (1 - fy)
+					} else {
+						wi *= fy
+					}
 
-			// When too much water evaporates, the droplet's journey ends
-			if water < 0.01 {
-				dropletsEvaporated++
-				break
+					i, j := ix+di, iy+dj
+					if i >= 0 && i < width && j >= 0 && j < height {
+						// Limit erosion to prevent negative heights
+						erode := math.Min(erosionAmount*wi, result.Data[j*stride+i])
+						result.Data[j*stride+i] -= erode
+						sediment += erode
+						totalWeight += wi
+						stats.totalEroded += erode
+					}
+				}
 			}
 
-			// Move to new position
-			x, y = newX, newY
-		}
-
-		totalSteps += steps
-		if steps > maxSteps {
-			maxSteps = steps
+			// Account for potential cells outside the map
+			if totalWeight > 0 {
+				additionalSediment := erosionAmount * (1 - totalWeight)
+				sediment += additionalSediment
+				stats.totalEroded += additionalSediment
+			}
 		}
-	}
 
-	simulationTime := time.Since(startDroplets)
-	fmt.Printf("  ├─ Simulación de erosión: %.3f s (%.0f gotas/s)\n",
-		simulationTime.Seconds(), float64(numDroplets)/simulationTime.Seconds())
+		// Update droplet properties
+		// Going downhill (deltaH < 0) speeds the droplet up; clamp so climbing
+		// never takes the square root of a negative number (NaN heights)
+		velocity = math.Sqrt(math.Max(velocity*velocity-deltaH*params.Gravity, 0))
+		water *= (1 - params.EvaporationRate)
 
-	// Mostrar estadísticas
-	fmt.Printf("  ├─ Estadísticas:\n")
-	fmt.Printf("  │  ├─ Pasos promedio por gota: %.1f (máx: %d)\n", float64(totalSteps)/float64(numDroplets), maxSteps)
-	fmt.Printf("  │  ├─ Gotas evaporadas: %d (%.1f%%)\n", dropletsEvaporated, float64(dropletsEvaporated)/float64(numDroplets)*100)
-	fmt.Printf("  │  ├─ Gotas fuera del mapa: %d (%.1f%%)\n", dropletsOffMap, float64(dropletsOffMap)/float64(numDroplets)*100)
-	fmt.Printf("  │  ├─ Gotas sin dirección: %d (%.1f%%)\n", dropletsNoDirection, float64(dropletsNoDirection)/float64(numDroplets)*100)
-	fmt.Printf("  │  ├─ Material erosionado: %.1f unidades\n", totalEroded)
-	fmt.Printf("  │  └─ Material depositado: %.1f unidades\n", totalDeposited)
+		// When too much water evaporates, the droplet's journey ends
+		if water < 0.01 {
+			stats.dropletsEvaporated++
+			break
+		}
 
-	fmt.Printf("  └─ Tiempo total de erosión: %.3f s\n", time.Since(startTotal).Seconds())
+		// Move to new position
+		x, y = newX, newY
+	}
 
-	return result
+	stats.totalSteps += steps
+	if steps > stats.maxSteps {
+		stats.maxSteps = steps
+	}
 }
 
 // ApplyErosionAndClamp aplica erosión hidráulica y luego asegura que todos los valores
 // permanezcan dentro del rango [-1, 1]
-func ApplyErosionAndClamp(heightmap [][]float64, numDroplets int, params ErosionParams) [][]float64 {
+func ApplyErosionAndClamp(heightmap *Heightmap, numDroplets int, params ErosionParams) *Heightmap {
 	startTotal := time.Now()
 	fmt.Printf("Iniciando erosión con límites...\n")
 
@@ -298,19 +396,17 @@ func ApplyErosionAndClamp(heightmap [][]float64, numDroplets int, params Erosion
 	clampedAbove := 0
 	clampedBelow := 0
 
-	for i := range result {
-		for j := range result[i] {
-			if result[i][j] > 1.0 {
-				result[i][j] = 1.0
-				clampedAbove++
-			} else if result[i][j] < -1.0 {
-				result[i][j] = -1.0
-				clampedBelow++
-			}
+	for i, v := range result.Data {
+		if v > 1.0 {
+			result.Data[i] = 1.0
+			clampedAbove++
+		} else if v < -1.0 {
+			result.Data[i] = -1.0
+			clampedBelow++
 		}
 	}
 
-	totalCells := len(result) * len(result[0])
+	totalCells := len(result.Data)
 	fmt.Printf("  ├─ Limitación de valores: %.3f ms\n", float64(time.Since(startClamp).Microseconds())/1000)
 	fmt.Printf("  ├─ Celdas limitadas: %d de %d (%.2f%%)\n",
 		clampedAbove+clampedBelow, totalCells,
@@ -0,0 +1,238 @@
+package terrain
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Las mallas de GenerateHeightmapMesh guardan la altura como -z para el renderizador, con
+// las caras orientadas hacia -altura. Los exportadores de intercambio las giran para que la
+// altura quede en el eje vertical de cada formato (Y en OBJ y glTF, Z en STL) e invierten el
+// orden de los vértices de cada cara para que las normales apunten hacia arriba.
+
+// uprightMesh devuelve los vértices girados y las caras con el orden invertido. Con yUp las
+// posiciones son (x, altura, y); sin él, (x, -y, altura). Ambos giros conservan la
+// orientación del mapa visto desde arriba.
+func uprightMesh(vertices [][3]float64, faces [][3]int, yUp bool) ([][3]float64, [][3]int) {
+	upright := make([][3]float64, len(vertices))
+	for i, v := range vertices {
+		if yUp {
+			upright[i] = [3]float64{v[0], -v[2], v[1]}
+		} else {
+			upright[i] = [3]float64{v[0], -v[1], -v[2]}
+		}
+	}
+	flipped := make([][3]int, len(faces))
+	for i, f := range faces {
+		flipped[i] = [3]int{f[0], f[2], f[1]}
+	}
+	return upright, flipped
+}
+
+// faceNormal devuelve la normal (sin normalizar, de módulo el doble del área) de la cara
+func faceNormal(a, b, c [3]float64) [3]float64 {
+	u := [3]float64{b[0] - a[0], b[1] - a[1], b[2] - a[2]}
+	v := [3]float64{c[0] - a[0], c[1] - a[1], c[2] - a[2]}
+	return [3]float64{
+		u[1]*v[2] - u[2]*v[1],
+		u[2]*v[0] - u[0]*v[2],
+		u[0]*v[1] - u[1]*v[0],
+	}
+}
+
+// unitVector devuelve el vector con módulo 1 (o el vector nulo)
+func unitVector(n [3]float64) [3]float64 {
+	l := math.Sqrt(n[0]*n[0] + n[1]*n[1] + n[2]*n[2])
+	if l == 0 {
+		return n
+	}
+	return [3]float64{n[0] / l, n[1] / l, n[2] / l}
+}
+
+// vertexNormals calcula las normales suavizadas de cada vértice, ponderando las caras que
+// lo comparten por su área
+func vertexNormals(vertices [][3]float64, faces [][3]int) [][3]float64 {
+	normals := make([][3]float64, len(vertices))
+	for _, f := range faces {
+		n := faceNormal(vertices[f[0]], vertices[f[1]], vertices[f[2]])
+		for _, i := range f {
+			normals[i][0] += n[0]
+			normals[i][1] += n[1]
+			normals[i][2] += n[2]
+		}
+	}
+	for i := range normals {
+		normals[i] = unitVector(normals[i])
+	}
+	return normals
+}
+
+// planarUVs proyecta los vértices de la malla original sobre su rectángulo (x, y): u crece
+// con x y v con y, de 0 a 1, de modo que (0, 0) es la esquina de la fila 0 del heightmap
+func planarUVs(vertices [][3]float64) [][2]float64 {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, v := range vertices {
+		minX, maxX = math.Min(minX, v[0]), math.Max(maxX, v[0])
+		minY, maxY = math.Min(minY, v[1]), math.Max(maxY, v[1])
+	}
+	spanX := math.Max(maxX-minX, 1e-12)
+	spanY := math.Max(maxY-minY, 1e-12)
+
+	uvs := make([][2]float64, len(vertices))
+	for i, v := range vertices {
+		uvs[i] = [2]float64{(v[0] - minX) / spanX, (v[1] - minY) / spanY}
+	}
+	return uvs
+}
+
+// BakeColorTexture genera una textura con un píxel por celda y los mismos colores que
+// GenerateHeightmapMesh asigna a los vértices, alineada con las UV de los exportadores
+func BakeColorTexture(heightmap *Heightmap) *image.RGBA {
+	minHeight, maxHeight := heightmap.Range()
+	img := image.NewRGBA(image.Rect(0, 0, heightmap.Width, heightmap.Height))
+	for y := 0; y < heightmap.Height; y++ {
+		for x := 0; x < heightmap.Width; x++ {
+			c := ColorFromHeight(heightmap.At(x, y), minHeight, maxHeight)
+			img.SetRGBA(x, y, color.RGBA{colorByte(c[0]), colorByte(c[1]), colorByte(c[2]), 255})
+		}
+	}
+	return img
+}
+
+// SaveOBJ guarda la malla en formato Wavefront OBJ con coordenadas de textura, normales
+// suavizadas y, si colors no es nil, el color de cada vértice (extensión "v x y z r g b"
+// que leen Blender y MeshLab). El eje Y es la altura.
+func SaveOBJ(filename string, vertices [][3]float64, faces [][3]int, colors [][3]float64) error {
+	startTotal := time.Now()
+	fmt.Printf("Guardando malla en archivo OBJ: %s\n", filename)
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriterSize(file, 1<<16)
+
+	startNormals := time.Now()
+	uvs := planarUVs(vertices)
+	positions, faces := uprightMesh(vertices, faces, true)
+	normals := vertexNormals(positions, faces)
+	fmt.Printf("  ├─ Cálculo de normales y UV: %.3f ms\n",
+		float64(time.Since(startNormals).Microseconds())/1000)
+
+	startVertices := time.Now()
+	fmt.Fprintf(writer, "# SimpleNoiseGenerator: %d vértices, %d caras\n", len(positions), len(faces))
+	b := make([]byte, 0, 128)
+	appendFloats := func(prefix string, values ...float64) {
+		b = append(b[:0], prefix...)
+		for _, f := range values {
+			b = append(b, ' ')
+			b = strconv.AppendFloat(b, float64(float32(f)), 'g', -1, 32)
+		}
+		b = append(b, '\n')
+		writer.Write(b)
+	}
+	for i, v := range positions {
+		if colors != nil {
+			c := colors[i]
+			appendFloats("v", v[0], v[1], v[2], c[0], c[1], c[2])
+		} else {
+			appendFloats("v", v[0], v[1], v[2])
+		}
+	}
+	// OBJ sitúa v = 0 en la parte inferior de la imagen
+	for _, uv := range uvs {
+		appendFloats("vt", uv[0], 1-uv[1])
+	}
+	for _, n := range normals {
+		appendFloats("vn", n[0], n[1], n[2])
+	}
+	fmt.Printf("  ├─ Escritura de %d vértices: %.3f ms\n",
+		len(positions), float64(time.Since(startVertices).Microseconds())/1000)
+
+	// Índices desde 1, con la misma posición, UV y normal por vértice
+	startFaces := time.Now()
+	for _, f := range faces {
+		b = append(b[:0], 'f')
+		for _, i := range f {
+			b = append(b, ' ')
+			for k := 0; k < 3; k++ {
+				if k > 0 {
+					b = append(b, '/')
+				}
+				b = strconv.AppendInt(b, int64(i+1), 10)
+			}
+		}
+		b = append(b, '\n')
+		writer.Write(b)
+	}
+	fmt.Printf("  ├─ Escritura de %d caras: %.3f ms\n",
+		len(faces), float64(time.Since(startFaces).Microseconds())/1000)
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("  └─ Tiempo total guardado OBJ: %.3f ms\n",
+		float64(time.Since(startTotal).Microseconds())/1000)
+
+	return file.Close()
+}
+
+// SaveSTL guarda la malla en formato STL binario (sin colores) con la altura en el eje Z
+func SaveSTL(filename string, vertices [][3]float64, faces [][3]int) error {
+	startTotal := time.Now()
+	fmt.Printf("Guardando malla en archivo STL: %s\n", filename)
+
+	if uint64(len(faces)) > math.MaxUint32 {
+		return fmt.Errorf("demasiadas caras para STL: %d", len(faces))
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriterSize(file, 1<<16)
+
+	// Cabecera de 80 bytes (que no debe empezar por "solid") y número de triángulos
+	header := make([]byte, 80)
+	copy(header, "SimpleNoiseGenerator terrain")
+	writer.Write(header)
+	binary.Write(writer, binary.LittleEndian, uint32(len(faces)))
+
+	startFaces := time.Now()
+	positions, faces := uprightMesh(vertices, faces, false)
+	b := make([]byte, 0, 50)
+	for _, f := range faces {
+		n := unitVector(faceNormal(positions[f[0]], positions[f[1]], positions[f[2]]))
+		b = b[:0]
+		for _, c := range n {
+			b = binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(c)))
+		}
+		for _, i := range f {
+			for _, c := range positions[i] {
+				b = binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(c)))
+			}
+		}
+		b = append(b, 0, 0) // Atributos
+		writer.Write(b)
+	}
+	fmt.Printf("  ├─ Escritura de %d triángulos: %.3f ms\n",
+		len(faces), float64(time.Since(startFaces).Microseconds())/1000)
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("  └─ Tiempo total guardado STL: %.3f ms\n",
+		float64(time.Since(startTotal).Microseconds())/1000)
+
+	return file.Close()
+}
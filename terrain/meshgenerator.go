@@ -1,7 +1,6 @@
 package terrain
 
 import (
-	"bufio"
 	"fmt"
 	"math"
 	"os"
@@ -19,45 +18,33 @@ func ColorFromHeight(value float64, min, max float64) [3]float64 {
 	}
 }
 
-// SavePLY guarda el terreno como un archivo 3D en formato PLY
+// SavePLY guarda el terreno como un archivo 3D en formato PLY binario (little endian)
 func SavePLY(filename string, vertices [][3]float64, faces [][3]int, colors [][3]float64) error {
+	return SavePLYFormat(filename, vertices, faces, colors, PLYBinary)
+}
+
+// SavePLYFormat guarda el terreno como un archivo 3D en formato PLY binario o ASCII
+func SavePLYFormat(filename string, vertices [][3]float64, faces [][3]int, colors [][3]float64, format PLYFormat) error {
 	startTotal := time.Now()
-	fmt.Printf("Guardando malla en archivo PLY: %s\n", filename)
+	fmt.Printf("Guardando malla en archivo PLY (%s): %s\n", format, filename)
 
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
-
-	startHeader := time.Now()
-	// Escribir cabecera PLY
-	fmt.Fprintf(writer, "ply\n")
-	fmt.Fprintf(writer, "format ascii 1.0\n")
-	fmt.Fprintf(writer, "element vertex %d\n", len(vertices))
-	fmt.Fprintf(writer, "property float x\n")
-	fmt.Fprintf(writer, "property float y\n")
-	fmt.Fprintf(writer, "property float z\n")
-	fmt.Fprintf(writer, "property uchar red\n")   // Cambiado a uchar (0-255)
-	fmt.Fprintf(writer, "property uchar green\n") // Cambiado a uchar (0-255)
-	fmt.Fprintf(writer, "property uchar blue\n")  // Cambiado a uchar (0-255)
-	fmt.Fprintf(writer, "element face %d\n", len(faces))
-	fmt.Fprintf(writer, "property list uchar int vertex_indices\n")
-	fmt.Fprintf(writer, "end_header\n")
-	fmt.Printf("  ├─ Escritura de cabecera: %.3f ms\n",
-		float64(time.Since(startHeader).Microseconds())/1000)
-
-	// Escribir vértices con colores (convertidos a enteros 0-255)
+
+	writer, err := NewPLYWriter(file, format, len(vertices), len(faces))
+	if err != nil {
+		return err
+	}
+
+	// Escribir vértices con colores
 	startVertices := time.Now()
 	for i, v := range vertices {
-		c := colors[i]
-		// Convertir colores float (0-1) a uchar (0-255)
-		r := int(math.Round(c[0] * 255))
-		g := int(math.Round(c[1] * 255))
-		b := int(math.Round(c[2] * 255))
-		fmt.Fprintf(writer, "%.2f %.2f %.2f %d %d %d\n", v[0], v[1], v[2], r, g, b)
+		if err := writer.WriteVertex(v, colors[i]); err != nil {
+			return err
+		}
 	}
 	fmt.Printf("  ├─ Escritura de %d vértices: %.3f ms\n",
 		len(vertices), float64(time.Since(startVertices).Microseconds())/1000)
@@ -65,38 +52,116 @@ func SavePLY(filename string, vertices [][3]float64, faces [][3]int, colors [][3
 	// Escribir caras
 	startFaces := time.Now()
 	for _, f := range faces {
-		fmt.Fprintf(writer, "3 %d %d %d\n", f[0], f[1], f[2])
+		if err := writer.WriteFace(f); err != nil {
+			return err
+		}
 	}
 	fmt.Printf("  ├─ Escritura de %d caras: %.3f ms\n",
 		len(faces), float64(time.Since(startFaces).Microseconds())/1000)
 
+	if err := writer.Close(); err != nil {
+		return err
+	}
 	fmt.Printf("  └─ Tiempo total guardado PLY: %.3f ms\n",
 		float64(time.Since(startTotal).Microseconds())/1000)
 
-	return nil
+	return file.Close()
 }
 
-// GenerateHeightmapMesh crea una malla 3D completa a partir de un heightmap 2D
-func GenerateHeightmapMesh(heightmap [][]float64) ([][3]float64, [][3]int, [][3]float64) {
+// SaveHeightmapPLY genera la malla del heightmap (la misma que GenerateHeightmapMesh) y la
+// escribe directamente en un archivo PLY, sin construir los arrays de vértices, caras y colores
+func SaveHeightmapPLY(filename string, heightmap *Heightmap, format PLYFormat) error {
 	startTotal := time.Now()
-	height := len(heightmap)
-	width := len(heightmap[0])
-	fmt.Printf("Iniciando generación de malla %dx%d (%d vértices)...\n",
-		width, height, width*height)
+	height := heightmap.Height
+	width := heightmap.Width
+	numVertices := width * height
+	numFaces := 2 * max(height-1, 0) * max(width-1, 0)
+	fmt.Printf("Guardando malla %dx%d en archivo PLY (%s): %s\n", width, height, format, filename)
 
-	// Encontrar valores mínimo y máximo para la coloración
-	startMinMax := time.Now()
-	minHeight, maxHeight := heightmap[0][0], heightmap[0][0]
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer, err := NewPLYWriter(file, format, numVertices, numFaces)
+	if err != nil {
+		return err
+	}
+
+	// Vértices con la altura invertida y color por altura normalizada, como en GenerateHeightmapMesh
+	startVertices := time.Now()
+	minHeight, maxHeight := heightmap.Range()
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
-			if heightmap[y][x] < minHeight {
-				minHeight = heightmap[y][x]
+			h := heightmap.At(x, y)
+			v := [3]float64{float64(x) * heightmap.CellSize, float64(y) * heightmap.CellSize, -h}
+			if err := writer.WriteVertex(v, ColorFromHeight(h, minHeight, maxHeight)); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Printf("  ├─ Escritura de %d vértices: %.3f ms\n",
+		numVertices, float64(time.Since(startVertices).Microseconds())/1000)
+
+	// Dos triángulos por celda, con el mismo orden de vértices que GenerateHeightmapMesh
+	startFaces := time.Now()
+	for y := 0; y < height-1; y++ {
+		for x := 0; x < width-1; x++ {
+			topLeft := y*width + x
+			topRight := y*width + (x + 1)
+			bottomLeft := (y+1)*width + x
+			bottomRight := (y+1)*width + (x + 1)
+			if err := writer.WriteFace([3]int{topLeft, topRight, bottomLeft}); err != nil {
+				return err
 			}
-			if heightmap[y][x] > maxHeight {
-				maxHeight = heightmap[y][x]
+			if err := writer.WriteFace([3]int{bottomLeft, topRight, bottomRight}); err != nil {
+				return err
 			}
 		}
 	}
+	fmt.Printf("  ├─ Escritura de %d caras: %.3f ms\n",
+		numFaces, float64(time.Since(startFaces).Microseconds())/1000)
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	if info, err := file.Stat(); err == nil {
+		fmt.Printf("  ├─ Tamaño del archivo: %.2f MB\n", float64(info.Size())/(1024*1024))
+	}
+	fmt.Printf("  └─ Tiempo total guardado PLY: %.3f ms\n",
+		float64(time.Since(startTotal).Microseconds())/1000)
+
+	return file.Close()
+}
+
+// MeshOptions configura la malla que genera GenerateHeightmapMeshWithOptions
+type MeshOptions struct {
+	Adaptive bool    // Malla adaptativa RTIN en lugar de dos triángulos por celda
+	MaxError float64 // Error vertical máximo de la malla adaptativa (unidades del heightmap)
+}
+
+// GenerateHeightmapMeshWithOptions crea la malla 3D del heightmap. En modo adaptativo los
+// triángulos se adaptan al relieve (RTIN) directamente sobre el heightmap, sin generar ni
+// simplificar la malla completa; con MaxError 0 solo se agrupan las zonas planas.
+func GenerateHeightmapMeshWithOptions(heightmap *Heightmap, options MeshOptions) ([][3]float64, [][3]int, [][3]float64) {
+	if options.Adaptive {
+		return NewRTIN(heightmap).Mesh(options.MaxError)
+	}
+	return GenerateHeightmapMesh(heightmap)
+}
+
+// GenerateHeightmapMesh crea una malla 3D completa a partir de un heightmap 2D
+func GenerateHeightmapMesh(heightmap *Heightmap) ([][3]float64, [][3]int, [][3]float64) {
+	startTotal := time.Now()
+	height := heightmap.Height
+	width := heightmap.Width
+	fmt.Printf("Iniciando generación de malla %dx%d (%d vértices)...\n",
+		width, height, width*height)
+
+	// Encontrar valores mínimo y máximo para la coloración
+	startMinMax := time.Now()
+	minHeight, maxHeight := heightmap.Range()
 	fmt.Printf("  ├─ Cálculo de rango de alturas: %.3f ms\n",
 		float64(time.Since(startMinMax).Microseconds())/1000)
 	fmt.Printf("  │  ├─ Altura mínima: %.2f\n", minHeight)
@@ -109,15 +174,15 @@ func GenerateHeightmapMesh(heightmap [][]float64) ([][3]float64, [][3]int, [][3]
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
 			idx := y*width + x
-			h := heightmap[y][x]
+			h := heightmap.At(x, y)
 
 			// Posición del vértice
-			// 1. Mantenemos x como está
-			// 2. Mantenemos y como está (sin invertir)
+			// 1. Mantenemos x como está (escalada por el tamaño de celda)
+			// 2. Mantenemos y como está (sin invertir, escalada por el tamaño de celda)
 			// 3. INVERTIMOS el valor de altura (h) para corregir la orientación del eje Z
 			vertices[idx] = [3]float64{
-				float64(x),
-				float64(y),
+				float64(x) * heightmap.CellSize,
+				float64(y) * heightmap.CellSize,
 				-h, // Invertimos el signo de la altura para corregir la orientación en el eje Z
 			}
 
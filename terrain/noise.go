@@ -0,0 +1,79 @@
+package terrain
+
+import (
+	"math/rand"
+)
+
+// Noise is a seeded source of coherent 2D noise with values roughly in [-1, 1].
+type Noise interface {
+	Eval2(x, y float64) float64
+	Seed() int64
+}
+
+// Noise3 is a Noise that can also be sampled in 3D (volumes, animated 2D noise).
+type Noise3 interface {
+	Noise
+	Eval3(x, y, z float64) float64
+}
+
+// Noise4 is a Noise that can also be sampled in 4D (e.g. seamless tiles on a torus).
+type Noise4 interface {
+	Noise3
+	Eval4(x, y, z, w float64) float64
+}
+
+// GradientNoise is a Noise that can also return its analytical partial derivatives.
+type GradientNoise interface {
+	Noise
+	Eval2WithGradient(x, y float64) (value, dx, dy float64)
+}
+
+// newPermutation builds the duplicated 512-entry permutation table for a seed.
+func newPermutation(seed int64) [512]int {
+	src := rand.NewSource(seed)
+	r := rand.New(src)
+
+	perm := [256]int{}
+	for i := range perm {
+		perm[i] = i
+	}
+
+	// Shuffle the permutation array using the provided seed
+	for i := len(perm) - 1; i > 0; i-- {
+		j := r.Intn(i + 1)
+		perm[i], perm[j] = perm[j], perm[i]
+	}
+
+	// Duplicate the permutation array to avoid overflow
+	var table [512]int
+	for i := 0; i < 512; i++ {
+		table[i] = perm[i%256]
+	}
+	return table
+}
+
+// hash2 mixes a seed and integer lattice coordinates into a well-distributed 64-bit hash.
+func hash2(seed int64, x, y int) uint64 {
+	h := uint64(seed) ^ uint64(int64(x))*0x9E3779B97F4A7C15 ^ uint64(int64(y))*0xC2B2AE3D27D4EB4F
+	h ^= h >> 30
+	h *= 0xBF58476D1CE4E5B9
+	h ^= h >> 27
+	h *= 0x94D049BB133111EB
+	h ^= h >> 31
+	return h
+}
+
+// fade is the quintic smoothstep 6t^5 - 15t^4 + 10t^3 used by lattice noises.
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+// fadeDerivative is the derivative of fade: 30t^4 - 60t^3 + 30t^2.
+func fadeDerivative(t float64) float64 {
+	return 30 * t * t * (t*(t-2) + 1)
+}
+
+// lerp linearly interpolates between a and b.
+func lerp(a, b, t float64) float64 {
+	return a + t*(b-a)
+}
@@ -0,0 +1,675 @@
+package terrain
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// FractalType selects how octaves are combined into the final height.
+type FractalType int
+
+const (
+	FractalFBM           FractalType = iota // Plain fractional Brownian motion
+	FractalBillow                           // Sum of absolute values: puffy, rounded hills
+	FractalRidged                           // Musgrave ridged multifractal: sharp ridgelines
+	FractalHybrid                           // Musgrave hybrid multifractal: smooth valleys, rough peaks
+	FractalHeterogeneous                    // Musgrave heterogeneous terrain: detail grows with altitude
+	FractalDerivative                       // Quílez derivative-dampened fBm: eroded-looking slopes
+)
+
+// String returns the name of the fractal type.
+func (t FractalType) String() string {
+	switch t {
+	case FractalBillow:
+		return "billow"
+	case FractalRidged:
+		return "ridged"
+	case FractalHybrid:
+		return "hybrid"
+	case FractalHeterogeneous:
+		return "heterogeneous"
+	case FractalDerivative:
+		return "derivative"
+	default:
+		return "fbm"
+	}
+}
+
+// MultifractalParams holds Musgrave's multifractal controls.
+type MultifractalParams struct {
+	H      float64 // Fractal increment: octave weights are frequency^-H
+	Offset float64 // Shifts the noise before it is weighted (ridge sharpness for ridged)
+	Gain   float64 // Feedback of each octave into the next (ridged only)
+}
+
+// WarpMode selects how many layers of domain warping displace the sampling coordinates.
+type WarpMode int
+
+const (
+	WarpNone   WarpMode = iota // Sample the noise directly
+	WarpSingle                 // f(p + a·q(p))
+	WarpDouble                 // f(p + b·r(p + a·q(p))), Quílez-style folded shapes
+)
+
+// WarpLayer configures one displacement field of a domain warp.
+type WarpLayer struct {
+	Seed     int64   // Seed of the OpenSimplex field driving the displacement
+	Scale    float64 // Pixels per noise unit of the displacement field
+	Strength float64 // Maximum displacement in pixels
+	Octaves  int     // Octaves of the displacement field (at least 1)
+}
+
+// WarpParams configures domain warping of the noise coordinates before sampling.
+type WarpParams struct {
+	Mode   WarpMode
+	First  WarpLayer // Used by WarpSingle and WarpDouble
+	Second WarpLayer // Used by WarpDouble only
+}
+
+// NoiseParams configures the fractal sum used to build a noise map.
+type NoiseParams struct {
+	Scale          float64 // Pixels per noise unit at the first octave
+	Octaves        int     // Number of octaves summed
+	Lacunarity     float64 // Frequency multiplier between octaves (<= 0 uses 2.0)
+	Persistence    float64 // Amplitude multiplier (gain) between octaves (<= 0 uses 0.5)
+	OffsetX        float64 // Origin offset in noise units
+	OffsetY        float64 // Origin offset in noise units
+	OctaveSeeds    bool    // Shift each octave by a seed-derived offset
+	OctaveRotation bool    // Rotate each octave by a seed-derived angle
+	TilePeriod     int     // Repeat period in pixels (0 disables tiling)
+
+	Fractal FractalType        // How octaves are combined
+	Ridged  MultifractalParams // Used by FractalRidged
+	Hybrid  MultifractalParams // Used by FractalHybrid
+	Hetero  MultifractalParams // Used by FractalHeterogeneous
+
+	DerivativeDamping float64 // Slope damping of FractalDerivative (1 matches Quílez)
+
+	Warp WarpParams // Domain warping applied before the fractal is sampled
+
+	Workers int // Goroutines generating rows in parallel (<= 0 uses every CPU)
+}
+
+// DefaultNoiseParams returns the classic fBm settings: lacunarity 2 and persistence 0.5.
+// The multifractal parameters are filled with Musgrave's usual values so switching
+// Fractal is enough to try another terrain type.
+func DefaultNoiseParams(scale float64, octaves int) NoiseParams {
+	return NoiseParams{
+		Scale:       scale,
+		Octaves:     octaves,
+		Lacunarity:  2.0,
+		Persistence: 0.5,
+		Fractal:     FractalFBM,
+		Ridged:      MultifractalParams{H: 1.0, Offset: 1.0, Gain: 2.0},
+		Hybrid:      MultifractalParams{H: 0.25, Offset: 0.7},
+		Hetero:      MultifractalParams{H: 0.9, Offset: 1.0},
+
+		DerivativeDamping: 1.0,
+	}
+}
+
+// lacunarity returns the frequency multiplier between octaves. Unset (zero) values would
+// sample every octave after the first at frequency 0, so they fall back to the default 2.
+func (p NoiseParams) lacunarity() float64 {
+	if p.Lacunarity <= 0 {
+		return 2.0
+	}
+	return p.Lacunarity
+}
+
+// persistence returns the amplitude multiplier between octaves. Unset (zero) values would
+// silence every octave after the first, so they fall back to the default 0.5.
+func (p NoiseParams) persistence() float64 {
+	if p.Persistence <= 0 {
+		return 0.5
+	}
+	return p.Persistence
+}
+
+// CreateNoiseMap builds a mapSize x mapSize fBm heightmap in [-1, 1] from any Noise source.
+func CreateNoiseMap(noise Noise, mapSize int, mapScale float64, mapOctaves int, smoothingFunction func(float64) float64) *Heightmap {
+	return CreateNoiseMapWithParams(noise, mapSize, DefaultNoiseParams(mapScale, mapOctaves), smoothingFunction)
+}
+
+// CreateTileableNoiseMap builds a heightmap that repeats every tilePeriod pixels in x and y,
+// so its left/right and top/bottom edges join seamlessly. Noise sources implementing Noise4
+// are sampled on a 4D torus; any other source falls back to blending four offset samples.
+func CreateTileableNoiseMap(noise Noise, mapSize int, mapScale float64, mapOctaves int, tilePeriod int, smoothingFunction func(float64) float64) *Heightmap {
+	if tilePeriod <= 0 {
+		tilePeriod = mapSize
+	}
+	params := DefaultNoiseParams(mapScale, mapOctaves)
+	params.TilePeriod = tilePeriod
+	return CreateNoiseMapWithParams(noise, mapSize, params, smoothingFunction)
+}
+
+// CreateNoiseMapWithParams builds a mapSize x mapSize heightmap using the given fractal parameters.
+func CreateNoiseMapWithParams(noise Noise, mapSize int, params NoiseParams, smoothingFunction func(float64) float64) *Heightmap {
+	return generateNoiseGrid(noise, mapSize, params, smoothingFunction, 0, 0, 1)
+}
+
+// Chunk locates a square piece of an infinite world on a regular grid of chunks.
+type Chunk struct {
+	X, Y        int     // Chunk coordinates in the world grid
+	Size        int     // Cells per side; the chunk holds Size+1 samples so borders are shared
+	CellSpacing float64 // Pixels of noise space between samples (1 matches CreateNoiseMap)
+}
+
+// CreateNoiseChunk fills the (Size+1) x (Size+1) samples of a world chunk. Samples are
+// addressed by their integer world index, so the last row/column of a chunk is bit-identical
+// to the first row/column of its neighbour for the same noise, params and smoothing.
+func CreateNoiseChunk(noise Noise, chunk Chunk, params NoiseParams, smoothingFunction func(float64) float64) *Heightmap {
+	spacing := chunk.CellSpacing
+	if spacing <= 0 {
+		spacing = 1
+	}
+	return generateNoiseGrid(noise, chunk.Size+1, params, smoothingFunction,
+		chunk.X*chunk.Size, chunk.Y*chunk.Size, spacing)
+}
+
+// generateNoiseGrid fills a size x size grid whose sample (x, y) lies at world index
+// (originX+x, originY+y), i.e. pixel coordinates multiplied by spacing.
+func generateNoiseGrid(noise Noise, mapSize int, params NoiseParams, smoothingFunction func(float64) float64,
+	originX, originY int, spacing float64) *Heightmap {
+	startTotal := time.Now()
+	fmt.Printf("Iniciando generación de mapa de ruido %dx%d (escala: %.1f, octavas: %d)\n",
+		mapSize, mapSize, params.Scale, params.Octaves)
+
+	fmt.Printf("  ├─ Generador de ruido: %T (semilla: %d)\n", noise, noise.Seed())
+	fmt.Printf("  ├─ Tipo fractal: %s, lacunaridad: %.2f, persistencia: %.2f, origen: (%.2f, %.2f)\n",
+		params.Fractal, params.lacunarity(), params.persistence(), params.OffsetX, params.OffsetY)
+	if originX != 0 || originY != 0 || spacing != 1 {
+		fmt.Printf("  ├─ Región mundial: índice inicial (%d, %d), espaciado %.3f\n",
+			originX, originY, spacing)
+	}
+
+	// Reservar el heightmap contiguo
+	startHeightmap := time.Now()
+	heightmap := NewHeightmap(mapSize, mapSize)
+	heightmap.CellSize = spacing
+	fmt.Printf("  ├─ Creación de array heightmap: %.3f ms\n",
+		float64(time.Since(startHeightmap).Microseconds())/1000)
+
+	// Precompute octave frequencies, amplitudes and transforms
+	startPrecompute := time.Now()
+	sampler := newFractalSampler(noise, params)
+	fmt.Printf("  ├─ Precálculo de frecuencias y amplitudes: %.3f ms\n",
+		float64(time.Since(startPrecompute).Microseconds())/1000)
+	if params.TilePeriod > 0 {
+		fmt.Printf("  ├─ Modo repetible: periodo de %d píxeles\n", params.TilePeriod)
+	}
+	if params.Warp.Mode != WarpNone {
+		fmt.Printf("  ├─ Deformación de dominio: %d capa(s)\n", len(sampler.warps))
+	}
+
+	// Generate initial heightmap - Row-parallel version. Every row depends only on
+	// its coordinates, so the result is identical for any number of workers.
+	workers := params.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	workers = max(1, min(workers, mapSize))
+	fmt.Printf("  ├─ Trabajadores en paralelo: %d\n", workers)
+
+	startGeneration := time.Now()
+	rows := make(chan int, mapSize)
+	for y := 0; y < mapSize; y++ {
+		rows <- y
+	}
+	close(rows)
+
+	rowsDone := make(chan struct{}, mapSize)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for y := range rows {
+				py := float64(originY+y) * spacing
+				row := heightmap.Row(y)
+				for x := range row {
+					row[x] = sampler.Sample(float64(originX+x)*spacing, py)
+				}
+				rowsDone <- struct{}{}
+			}
+		}()
+	}
+
+	// Report progress from this goroutine as rows are completed
+	reportInterval := max(mapSize/10, 1)
+	for done := 1; done <= mapSize; done++ {
+		<-rowsDone
+		if done < mapSize && done%reportInterval == 0 {
+			pctComplete := float64(done) / float64(mapSize) * 100
+			timeElapsed := time.Since(startGeneration)
+			timeEstimated := time.Duration(float64(timeElapsed) / (float64(done) / float64(mapSize)))
+			timeRemaining := timeEstimated - timeElapsed
+			fmt.Printf("  │  ├─ %.1f%% completado - Tiempo restante: %.1f s\n",
+				pctComplete, timeRemaining.Seconds())
+		}
+	}
+	wg.Wait()
+	generationTime := time.Since(startGeneration)
+	totalEvals := mapSize * mapSize * sampler.evalsPerSample()
+	fmt.Printf("  ├─ Generación del mapa base: %.3f s (%.1f millones de eval./s)\n",
+		generationTime.Seconds(), float64(totalEvals)/(generationTime.Seconds()*1000000))
+
+	// Aplicar función de suavizado
+	startSmoothing := time.Now()
+	heightmap.Apply(smoothingFunction)
+	fmt.Printf("  ├─ Aplicación de filtro de suavizado: %.3f ms\n",
+		float64(time.Since(startSmoothing).Microseconds())/1000)
+
+	fmt.Printf("  └─ Tiempo total generación de mapa: %.3f s\n", time.Since(startTotal).Seconds())
+
+	return heightmap
+}
+
+// octave holds the precomputed frequency, amplitude and decorrelating transform of one octave.
+type octave struct {
+	frequency float64
+	amplitude float64
+	spectral  float64 // Multifractal weight frequency^-H
+	offsetX   float64
+	offsetY   float64
+	cos, sin  float64 // Rotation applied to the octave's coordinates
+}
+
+// fractalSampler evaluates the fractal sum of a Noise at pixel coordinates.
+// It is read-only once built, so it can be shared between goroutines.
+type fractalSampler struct {
+	noise        Noise
+	noise4       Noise4        // Non-nil when tiling on a 4D torus
+	gradNoise    GradientNoise // Non-nil when analytic derivatives are available
+	params       NoiseParams
+	octaves      []octave
+	amplitudeSum float64
+	multi        MultifractalParams // Parameters of the selected multifractal
+	multiMin     float64            // Lower bound used to normalize billow and multifractal output
+	multiMax     float64            // Upper bound used to normalize billow and multifractal output
+	warps        []domainWarp       // Displacement layers applied in order
+}
+
+// domainWarp is one displacement layer: a fractal field sampled twice for the x and y offsets.
+type domainWarp struct {
+	field    *fractalSampler
+	strength float64
+	shiftX   float64 // Pixel shift decorrelating the y displacement from the x one
+	shiftY   float64
+}
+
+// newFractalSampler precomputes the octave table for the given noise and parameters.
+func newFractalSampler(noise Noise, params NoiseParams) *fractalSampler {
+	fs := &fractalSampler{
+		noise:   noise,
+		params:  params,
+		octaves: make([]octave, params.Octaves),
+	}
+	if n4, ok := noise.(Noise4); ok && params.TilePeriod > 0 {
+		fs.noise4 = n4
+	}
+	if gn, ok := noise.(GradientNoise); ok && fs.noise4 == nil {
+		fs.gradNoise = gn
+	}
+	switch params.Fractal {
+	case FractalRidged:
+		fs.multi = params.Ridged
+	case FractalHybrid:
+		fs.multi = params.Hybrid
+	case FractalHeterogeneous:
+		fs.multi = params.Hetero
+	}
+
+	// Octave transforms are derived from the noise seed so they are reproducible
+	r := rand.New(rand.NewSource(noise.Seed()))
+	frequency, amplitude := 1.0, 1.0
+	for i := range fs.octaves {
+		o := octave{
+			frequency: frequency,
+			amplitude: amplitude,
+			spectral:  math.Pow(frequency, -fs.multi.H),
+			cos:       1,
+		}
+		if params.OctaveSeeds && i > 0 {
+			o.offsetX = r.Float64()*512 - 256
+			o.offsetY = r.Float64()*512 - 256
+		}
+		if params.OctaveRotation && i > 0 {
+			o.sin, o.cos = math.Sincos(r.Float64() * 2 * math.Pi)
+		}
+		fs.octaves[i] = o
+		fs.amplitudeSum += amplitude
+		frequency *= params.lacunarity()
+		amplitude *= params.persistence()
+	}
+
+	// Bounds used to normalize. The ridged sum peaks when every octave sits on a ridge; the
+	// products of hybrid and heterogeneous terrain, and billow's folded octaves, stay far
+	// from their theoretical extremes, so their bounds are measured instead
+	fs.warps = newDomainWarps(params)
+	if len(fs.octaves) == 0 {
+		return fs
+	}
+	switch params.Fractal {
+	case FractalRidged:
+		for _, o := range fs.octaves {
+			fs.multiMax += fs.multi.Offset * fs.multi.Offset * o.spectral
+		}
+	case FractalBillow:
+		fs.multiMin, fs.multiMax = fs.calibrate(fs.billow)
+	case FractalHybrid:
+		fs.multiMin, fs.multiMax = fs.calibrate(fs.hybrid)
+	case FractalHeterogeneous:
+		fs.multiMin, fs.multiMax = fs.calibrate(fs.hetero)
+	}
+	return fs
+}
+
+const (
+	calibrationSamples = 4096   // Points calibrate evaluates
+	calibrationSeed    = 0x5eed // Decorrelates the calibration points from the octave transforms
+)
+
+// calibrate estimates the range of a raw fractal sum from samples spread over 256 x 256
+// base-octave noise units. The points depend only on the noise seed, so every map and
+// chunk of the same fractal shares the bounds and chunks still join seamlessly.
+func (fs *fractalSampler) calibrate(raw func(px, py float64) float64) (float64, float64) {
+	r := rand.New(rand.NewSource(calibrationSeed ^ fs.noise.Seed()))
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i := 0; i < calibrationSamples; i++ {
+		v := raw(r.Float64()*256*fs.params.Scale, r.Float64()*256*fs.params.Scale)
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	// Larger maps reach slightly rarer extremes; leave room so they are not clipped flat
+	margin := (hi - lo) * 0.05
+	return lo - margin, hi + margin
+}
+
+// newDomainWarps builds the displacement layers requested by params.Warp.
+// Warp fields share the map's tile period so warped tileable maps still tile.
+func newDomainWarps(params NoiseParams) []domainWarp {
+	layers := []WarpLayer{}
+	switch params.Warp.Mode {
+	case WarpSingle:
+		layers = append(layers, params.Warp.First)
+	case WarpDouble:
+		layers = append(layers, params.Warp.First, params.Warp.Second)
+	}
+
+	warps := make([]domainWarp, len(layers))
+	for i, layer := range layers {
+		fieldParams := DefaultNoiseParams(layer.Scale, max(layer.Octaves, 1))
+		fieldParams.TilePeriod = params.TilePeriod
+		warps[i] = domainWarp{
+			field:    newFractalSampler(NewOpenSimplex(layer.Seed), fieldParams),
+			strength: layer.Strength,
+			shiftX:   5.2 * layer.Scale,
+			shiftY:   1.3 * layer.Scale,
+		}
+	}
+	return warps
+}
+
+// evalsPerSample returns how many noise evaluations a single Sample call performs.
+func (fs *fractalSampler) evalsPerSample() int {
+	evals := len(fs.octaves)
+	if fs.params.Fractal == FractalDerivative && fs.gradNoise == nil {
+		evals *= 5
+	}
+	if fs.params.TilePeriod > 0 && fs.noise4 == nil {
+		evals *= 4
+	}
+	for _, w := range fs.warps {
+		evals += 2 * w.field.evalsPerSample()
+	}
+	return evals
+}
+
+// warp displaces pixel coordinates through every warp layer. Each layer is sampled
+// at the previous layer's output but displaces the original point.
+func (fs *fractalSampler) warp(px, py float64) (float64, float64) {
+	wx, wy := px, py
+	for _, w := range fs.warps {
+		dx := w.field.Sample(wx, wy)
+		dy := w.field.Sample(wx+w.shiftX, wy+w.shiftY)
+		wx = px + dx*w.strength
+		wy = py + dy*w.strength
+	}
+	return wx, wy
+}
+
+// Sample returns the normalized fractal value at pixel coordinates (px, py).
+func (fs *fractalSampler) Sample(px, py float64) float64 {
+	px += fs.params.OffsetX * fs.params.Scale
+	py += fs.params.OffsetY * fs.params.Scale
+	if fs.params.TilePeriod <= 0 {
+		px, py = fs.warp(px, py)
+		return fs.fractal(px, py)
+	}
+
+	// Wrap into the first period up front so every repeat sees identical inputs
+	period := float64(fs.params.TilePeriod)
+	px, py = fs.warp(wrapPeriod(px, period), wrapPeriod(py, period))
+	if fs.noise4 != nil {
+		return fs.fractal(px, py)
+	}
+
+	// Without 4D noise, blend samples shifted by one period so opposite edges coincide
+	px = wrapPeriod(px, period)
+	py = wrapPeriod(py, period)
+	wx := px / period
+	wy := py / period
+	return fs.fractal(px, py)*(1-wx)*(1-wy) +
+		fs.fractal(px-period, py)*wx*(1-wy) +
+		fs.fractal(px, py-period)*(1-wx)*wy +
+		fs.fractal(px-period, py-period)*wx*wy
+}
+
+// wrapPeriod maps v into [0, period).
+func wrapPeriod(v, period float64) float64 {
+	v = math.Mod(v, period)
+	if v < 0 {
+		v += period
+	}
+	return v
+}
+
+// fractal combines all octaves at pixel coordinates into a value roughly in [-1, 1].
+// Billow and the multifractals return raw sums that are normalized here.
+func (fs *fractalSampler) fractal(px, py float64) float64 {
+	switch fs.params.Fractal {
+	case FractalBillow:
+		return fs.normalizeMulti(fs.billow(px, py))
+	case FractalRidged:
+		return fs.normalizeMulti(fs.ridged(px, py))
+	case FractalHybrid:
+		return fs.normalizeMulti(fs.hybrid(px, py))
+	case FractalHeterogeneous:
+		return fs.normalizeMulti(fs.hetero(px, py))
+	case FractalDerivative:
+		return fs.derivative(px, py)
+	default:
+		return fs.fbm(px, py)
+	}
+}
+
+// fbm sums all octaves at pixel coordinates and normalizes by the total amplitude.
+func (fs *fractalSampler) fbm(px, py float64) float64 {
+	var total float64
+	for i := range fs.octaves {
+		total += fs.sampleOctave(&fs.octaves[i], px, py) * fs.octaves[i].amplitude
+	}
+	return total / fs.amplitudeSum
+}
+
+// billow sums folded octaves |n| remapped to [-1, 1], giving rounded, puffy shapes.
+func (fs *fractalSampler) billow(px, py float64) float64 {
+	var total float64
+	for i := range fs.octaves {
+		n := fs.sampleOctave(&fs.octaves[i], px, py)
+		total += (2*math.Abs(n) - 1) * fs.octaves[i].amplitude
+	}
+	return total / fs.amplitudeSum
+}
+
+// ridged implements Musgrave's ridged multifractal: each octave is weighted by the
+// sharpness of the previous one so detail concentrates along the ridgelines.
+func (fs *fractalSampler) ridged(px, py float64) float64 {
+	var result float64
+	weight := 1.0
+	for i := range fs.octaves {
+		signal := fs.multi.Offset - math.Abs(fs.sampleOctave(&fs.octaves[i], px, py))
+		signal *= signal * weight
+		result += signal * fs.octaves[i].spectral
+
+		weight = math.Max(0, math.Min(1, signal*fs.multi.Gain))
+	}
+	return result
+}
+
+// hybrid implements Musgrave's hybrid multifractal: low areas stay smooth while
+// high areas accumulate the rougher, higher octaves.
+func (fs *fractalSampler) hybrid(px, py float64) float64 {
+	var result, weight float64
+	for i := range fs.octaves {
+		signal := (fs.sampleOctave(&fs.octaves[i], px, py) + fs.multi.Offset) * fs.octaves[i].spectral
+		if i == 0 {
+			result = signal
+			weight = signal
+			continue
+		}
+		weight = math.Min(weight, 1)
+		result += weight * signal
+		weight *= signal
+	}
+	return result
+}
+
+// hetero implements Musgrave's heterogeneous terrain: each octave is scaled by the
+// current altitude so lowlands are smooth and highlands rough.
+func (fs *fractalSampler) hetero(px, py float64) float64 {
+	var value float64
+	for i := range fs.octaves {
+		n := fs.sampleOctave(&fs.octaves[i], px, py)
+		if i == 0 {
+			value = fs.multi.Offset + n
+			continue
+		}
+		value += (n + fs.multi.Offset) * fs.octaves[i].spectral * value
+	}
+	return value
+}
+
+// derivative implements Quílez's derivative-dampened fBm: octaves are attenuated where
+// the accumulated slope is steep, which mimics erosion without simulating it.
+func (fs *fractalSampler) derivative(px, py float64) float64 {
+	var total, sumDx, sumDy float64
+	for i := range fs.octaves {
+		n, dx, dy := fs.octaveGradient(&fs.octaves[i], px, py)
+		sumDx += dx
+		sumDy += dy
+		damping := 1 + fs.params.DerivativeDamping*(sumDx*sumDx+sumDy*sumDy)
+		total += fs.octaves[i].amplitude * n / damping
+	}
+	return total / fs.amplitudeSum
+}
+
+// normalizeMulti maps a billow or multifractal sum from [multiMin, multiMax] to [-1, 1], clamping outliers.
+func (fs *fractalSampler) normalizeMulti(v float64) float64 {
+	if fs.multiMax <= fs.multiMin {
+		return 0
+	}
+	v = (v-fs.multiMin)/(fs.multiMax-fs.multiMin)*2 - 1
+	return math.Max(-1, math.Min(1, v))
+}
+
+// sampleOctave evaluates a single octave at pixel coordinates.
+func (fs *fractalSampler) sampleOctave(o *octave, px, py float64) float64 {
+	if fs.noise4 != nil {
+		// Tileable maps wrap each axis onto a circle of circumference TilePeriod;
+		// the octave rotation becomes a phase shift so the result stays periodic.
+		period := float64(fs.params.TilePeriod)
+		radius := period / (2 * math.Pi) / fs.params.Scale * o.frequency
+		phase := math.Atan2(o.sin, o.cos)
+		ax := 2*math.Pi*math.Mod(px, period)/period + phase
+		ay := 2*math.Pi*math.Mod(py, period)/period + phase
+		return fs.noise4.Eval4(radius*math.Cos(ax)+o.offsetX, radius*math.Sin(ax)+o.offsetX,
+			radius*math.Cos(ay)+o.offsetY, radius*math.Sin(ay)+o.offsetY)
+	}
+
+	nx := px / fs.params.Scale * o.frequency
+	ny := py / fs.params.Scale * o.frequency
+	return fs.noise.Eval2(nx*o.cos-ny*o.sin+o.offsetX, nx*o.sin+ny*o.cos+o.offsetY)
+}
+
+// octaveGradient evaluates a single octave and its derivatives with respect to the
+// octave's own (rotated, scaled) noise coordinates. Sources without analytic
+// derivatives, and torus-tiled maps, fall back to central differences.
+func (fs *fractalSampler) octaveGradient(o *octave, px, py float64) (float64, float64, float64) {
+	if fs.gradNoise != nil {
+		nx := px / fs.params.Scale * o.frequency
+		ny := py / fs.params.Scale * o.frequency
+		return fs.gradNoise.Eval2WithGradient(nx*o.cos-ny*o.sin+o.offsetX, nx*o.sin+ny*o.cos+o.offsetY)
+	}
+
+	// One hundredth of an octave unit, expressed in pixels
+	h := fs.params.Scale / o.frequency * 0.01
+	n := fs.sampleOctave(o, px, py)
+	dx := (fs.sampleOctave(o, px+h, py) - fs.sampleOctave(o, px-h, py)) / 0.02
+	dy := (fs.sampleOctave(o, px, py+h) - fs.sampleOctave(o, px, py-h)) / 0.02
+	return n, dx, dy
+}
+
+// sampleGradient returns the fractal value and its derivatives with respect to pixel
+// coordinates. Plain, unwarped, untiled fBm is differentiated analytically octave by
+// octave; every other configuration uses central differences of Sample.
+func (fs *fractalSampler) sampleGradient(px, py float64) (float64, float64, float64) {
+	if fs.gradNoise != nil && fs.params.Fractal == FractalFBM && len(fs.warps) == 0 && fs.params.TilePeriod <= 0 {
+		px += fs.params.OffsetX * fs.params.Scale
+		py += fs.params.OffsetY * fs.params.Scale
+
+		var value, dx, dy float64
+		for i := range fs.octaves {
+			o := &fs.octaves[i]
+			n, nx, ny := fs.octaveGradient(o, px, py)
+
+			// Undo the octave rotation and scale to get derivatives in pixel units
+			k := o.amplitude * o.frequency / fs.params.Scale
+			value += o.amplitude * n
+			dx += k * (o.cos*nx + o.sin*ny)
+			dy += k * (-o.sin*nx + o.cos*ny)
+		}
+		return value / fs.amplitudeSum, dx / fs.amplitudeSum, dy / fs.amplitudeSum
+	}
+
+	const h = 0.01
+	dx := (fs.Sample(px+h, py) - fs.Sample(px-h, py)) / (2 * h)
+	dy := (fs.Sample(px, py+h) - fs.Sample(px, py-h)) / (2 * h)
+	return fs.Sample(px, py), dx, dy
+}
+
+// FractalNoise exposes the fractal configured by NoiseParams as a function of pixel
+// coordinates, the same space CreateNoiseMapWithParams samples.
+type FractalNoise struct {
+	sampler *fractalSampler
+}
+
+// NewFractalNoise precomputes the octave table of a fractal built on the given noise.
+func NewFractalNoise(noise Noise, params NoiseParams) *FractalNoise {
+	return &FractalNoise{sampler: newFractalSampler(noise, params)}
+}
+
+// Eval2 returns the fractal value at pixel coordinates, before any smoothing function.
+func (f *FractalNoise) Eval2(px, py float64) float64 {
+	return f.sampler.Sample(px, py)
+}
+
+// Eval2WithGradient returns the fractal value and its partial derivatives with respect
+// to pixel coordinates, accumulated across octaves.
+func (f *FractalNoise) Eval2WithGradient(px, py float64) (value, dx, dy float64) {
+	return f.sampler.sampleGradient(px, py)
+}
@@ -1,14 +1,12 @@
 package terrain
 
 import (
-	"fmt"
 	"math"
-	"math/rand"
-	"time"
 )
 
 // OpenSimplex represents a noise generator with a permutation table and gradients.
 type OpenSimplex struct {
+	seed int64
 	perm [512]int
 }
 
@@ -17,28 +15,33 @@ var gradients = [8][2]float64{
 	{-1.0, 0.0}, {-0.7071, -0.7071}, {0.0, -1.0}, {0.7071, -0.7071},
 }
 
-// NewOpenSimplex initializes a new noise generator with a given seed.
-func NewOpenSimplex(seed int64) *OpenSimplex {
-	os := &OpenSimplex{}
-	src := rand.NewSource(seed)
-	r := rand.New(src)
+// gradients3 are the 12 cube-edge directions used by Eval3.
+var gradients3 = [12][3]float64{
+	{1, 1, 0}, {-1, 1, 0}, {1, -1, 0}, {-1, -1, 0},
+	{1, 0, 1}, {-1, 0, 1}, {1, 0, -1}, {-1, 0, -1},
+	{0, 1, 1}, {0, -1, 1}, {0, 1, -1}, {0, -1, -1},
+}
 
-	perm := [256]int{}
-	for i := range perm {
-		perm[i] = i
-	}
+// gradients4 are the 32 tesseract-edge directions used by Eval4.
+var gradients4 = [32][4]float64{
+	{0, 1, 1, 1}, {0, 1, 1, -1}, {0, 1, -1, 1}, {0, 1, -1, -1},
+	{0, -1, 1, 1}, {0, -1, 1, -1}, {0, -1, -1, 1}, {0, -1, -1, -1},
+	{1, 0, 1, 1}, {1, 0, 1, -1}, {1, 0, -1, 1}, {1, 0, -1, -1},
+	{-1, 0, 1, 1}, {-1, 0, 1, -1}, {-1, 0, -1, 1}, {-1, 0, -1, -1},
+	{1, 1, 0, 1}, {1, 1, 0, -1}, {1, -1, 0, 1}, {1, -1, 0, -1},
+	{-1, 1, 0, 1}, {-1, 1, 0, -1}, {-1, -1, 0, 1}, {-1, -1, 0, -1},
+	{1, 1, 1, 0}, {1, 1, -1, 0}, {1, -1, 1, 0}, {1, -1, -1, 0},
+	{-1, 1, 1, 0}, {-1, 1, -1, 0}, {-1, -1, 1, 0}, {-1, -1, -1, 0},
+}
 
-	// Shuffle the permutation array using the provided seed
-	for i := len(perm) - 1; i > 0; i-- {
-		j := r.Intn(i + 1)
-		perm[i], perm[j] = perm[j], perm[i]
-	}
+// NewOpenSimplex initializes a new noise generator with a given seed.
+func NewOpenSimplex(seed int64) *OpenSimplex {
+	return &OpenSimplex{seed: seed, perm: newPermutation(seed)}
+}
 
-	// Duplicate the permutation array to avoid overflow
-	for i := 0; i < 512; i++ {
-		os.perm[i] = perm[i%256]
-	}
-	return os
+// Seed returns the seed the generator was created with.
+func (os *OpenSimplex) Seed() int64 {
+	return os.seed
 }
 
 // Eval2 computes the 2D noise value at the given coordinates.
@@ -111,79 +114,239 @@ func (os *OpenSimplex) Eval2(x, y float64) float64 {
 	return 70.0 * (n0 + n1 + n2)
 }
 
-func CreateNoiseMap(mapseed int64, mapSize int, mapScale float64, mapOctaves int, smoothingFunction func(float64) float64) [][]float64 {
-	startTotal := time.Now()
-	fmt.Printf("Iniciando generación de mapa de ruido %dx%d (escala: %.1f, octavas: %d)\n",
-		mapSize, mapSize, mapScale, mapOctaves)
-
-	// Inicializar el generador de ruido
-	startNoise := time.Now()
-	noise := NewOpenSimplex(mapseed)
-	fmt.Printf("  ├─ Inicialización del generador: %.3f ms\n",
-		float64(time.Since(startNoise).Microseconds())/1000)
-
-	// Crear directamente un array 2D
-	startHeightmap := time.Now()
-	heightmap := make([][]float64, mapSize)
-	for i := range heightmap {
-		heightmap[i] = make([]float64, mapSize)
-	}
-	fmt.Printf("  ├─ Creación de array heightmap: %.3f ms\n",
-		float64(time.Since(startHeightmap).Microseconds())/1000)
-
-	// Precompute amplitudeSum, freqs and amps
-	startPrecompute := time.Now()
-	freqs := make([]float64, mapOctaves)
-	amps := make([]float64, mapOctaves)
-	persistence := 0.5
-	amplitudeSum := 0.0
-	for i := range mapOctaves {
-		freqs[i] = math.Pow(2, float64(i))
-		amps[i] = math.Pow(persistence, float64(i))
-		amplitudeSum += amps[i]
-	}
-	fmt.Printf("  ├─ Precálculo de frecuencias y amplitudes: %.3f ms\n",
-		float64(time.Since(startPrecompute).Microseconds())/1000)
-
-	// Generate initial heightmap - Sequential version
-	startGeneration := time.Now()
-	totalEvals := 0
-	for y := 0; y < mapSize; y++ {
-		if y > 0 && y%(mapSize/10) == 0 {
-			pctComplete := float64(y) / float64(mapSize) * 100
-			timeElapsed := time.Since(startGeneration)
-			timeEstimated := time.Duration(float64(timeElapsed) / (float64(y) / float64(mapSize)))
-			timeRemaining := timeEstimated - timeElapsed
-			fmt.Printf("  │  ├─ %.1f%% completado - Tiempo restante: %.1f s\n",
-				pctComplete, timeRemaining.Seconds())
+// Eval2WithGradient computes the 2D noise value together with its analytical
+// partial derivatives with respect to x and y.
+func (os *OpenSimplex) Eval2WithGradient(x, y float64) (value, dx, dy float64) {
+	const (
+		F2 = 0.3660254037844386  // (sqrt(3) - 1) / 2
+		G2 = 0.21132486540518713 // (3 - sqrt(3)) / 6
+	)
+
+	// Same lattice walk as Eval2
+	s := (x + y) * F2
+	i := int(math.Floor(x + s))
+	j := int(math.Floor(y + s))
+	t := float64(i+j) * G2
+	x0 := x - (float64(i) - t)
+	y0 := y - (float64(j) - t)
+
+	var i1, j1 int
+	if x0 > y0 {
+		i1, j1 = 1, 0
+	} else {
+		i1, j1 = 0, 1
+	}
+
+	x1 := x0 - float64(i1) + G2
+	y1 := y0 - float64(j1) + G2
+	x2 := x0 - 1.0 + 2.0*G2
+	y2 := y0 - 1.0 + 2.0*G2
+
+	ii := i & 255
+	jj := j & 255
+	gi0 := os.perm[ii+os.perm[jj]] % 8
+	gi1 := os.perm[ii+i1+os.perm[jj+j1]] % 8
+	gi2 := os.perm[ii+1+os.perm[jj+1]] % 8
+
+	// Each corner contributes t^4 (g·d); its derivative is t^4 g - 8 t^3 (g·d) d
+	corner := func(gi int, cx, cy float64) {
+		t := 0.5 - cx*cx - cy*cy
+		if t < 0 {
+			return
+		}
+		g := gradients[gi]
+		dot := g[0]*cx + g[1]*cy
+		t2 := t * t
+		t3 := t2 * t
+		t4 := t2 * t2
+		value += t4 * dot
+		dx += t4*g[0] - 8*t3*dot*cx
+		dy += t4*g[1] - 8*t3*dot*cy
+	}
+	corner(gi0, x0, y0)
+	corner(gi1, x1, y1)
+	corner(gi2, x2, y2)
+
+	return 70.0 * value, 70.0 * dx, 70.0 * dy
+}
+
+// Eval3 computes the 3D noise value at the given coordinates.
+func (os *OpenSimplex) Eval3(x, y, z float64) float64 {
+	const (
+		F3 = 1.0 / 3.0
+		G3 = 1.0 / 6.0
+	)
+
+	// Skew input to the simplectic honeycomb
+	s := (x + y + z) * F3
+	i := int(math.Floor(x + s))
+	j := int(math.Floor(y + s))
+	k := int(math.Floor(z + s))
+
+	t := float64(i+j+k) * G3
+	x0 := x - (float64(i) - t)
+	y0 := y - (float64(j) - t)
+	z0 := z - (float64(k) - t)
+
+	// Determine which of the six tetrahedra we're in
+	var i1, j1, k1, i2, j2, k2 int
+	if x0 >= y0 {
+		if y0 >= z0 {
+			i1, j1, k1, i2, j2, k2 = 1, 0, 0, 1, 1, 0
+		} else if x0 >= z0 {
+			i1, j1, k1, i2, j2, k2 = 1, 0, 0, 1, 0, 1
+		} else {
+			i1, j1, k1, i2, j2, k2 = 0, 0, 1, 1, 0, 1
+		}
+	} else {
+		if y0 < z0 {
+			i1, j1, k1, i2, j2, k2 = 0, 0, 1, 0, 1, 1
+		} else if x0 < z0 {
+			i1, j1, k1, i2, j2, k2 = 0, 1, 0, 0, 1, 1
+		} else {
+			i1, j1, k1, i2, j2, k2 = 0, 1, 0, 1, 1, 0
 		}
+	}
+
+	// Offsets for the remaining corners
+	x1 := x0 - float64(i1) + G3
+	y1 := y0 - float64(j1) + G3
+	z1 := z0 - float64(k1) + G3
+	x2 := x0 - float64(i2) + 2.0*G3
+	y2 := y0 - float64(j2) + 2.0*G3
+	z2 := z0 - float64(k2) + 2.0*G3
+	x3 := x0 - 1.0 + 3.0*G3
+	y3 := y0 - 1.0 + 3.0*G3
+	z3 := z0 - 1.0 + 3.0*G3
+
+	ii := i & 255
+	jj := j & 255
+	kk := k & 255
+
+	// Calculate gradients from permutation table
+	gi0 := os.perm[ii+os.perm[jj+os.perm[kk]]] % 12
+	gi1 := os.perm[ii+i1+os.perm[jj+j1+os.perm[kk+k1]]] % 12
+	gi2 := os.perm[ii+i2+os.perm[jj+j2+os.perm[kk+k2]]] % 12
+	gi3 := os.perm[ii+1+os.perm[jj+1+os.perm[kk+1]]] % 12
 
-		for x := 0; x < mapSize; x++ {
-			var total float64
-			for i := 0; i < mapOctaves; i++ {
-				nx := float64(x) / mapScale * freqs[i]
-				ny := float64(y) / mapScale * freqs[i]
-				total += noise.Eval2(nx, ny) * amps[i]
-				totalEvals++
-			}
-			heightmap[y][x] = total / amplitudeSum
+	corner := func(gi int, dx, dy, dz float64) float64 {
+		t := 0.6 - dx*dx - dy*dy - dz*dz
+		if t < 0 {
+			return 0
 		}
+		t *= t
+		g := gradients3[gi]
+		return t * t * (g[0]*dx + g[1]*dy + g[2]*dz)
+	}
+
+	return 32.0 * (corner(gi0, x0, y0, z0) + corner(gi1, x1, y1, z1) +
+		corner(gi2, x2, y2, z2) + corner(gi3, x3, y3, z3))
+}
+
+// Eval4 computes the 4D noise value at the given coordinates.
+func (os *OpenSimplex) Eval4(x, y, z, w float64) float64 {
+	const (
+		F4 = 0.30901699437494745 // (sqrt(5) - 1) / 4
+		G4 = 0.1381966011250105  // (5 - sqrt(5)) / 20
+	)
+
+	// Skew input to the 4D simplectic grid
+	s := (x + y + z + w) * F4
+	i := int(math.Floor(x + s))
+	j := int(math.Floor(y + s))
+	k := int(math.Floor(z + s))
+	l := int(math.Floor(w + s))
+
+	t := float64(i+j+k+l) * G4
+	x0 := x - (float64(i) - t)
+	y0 := y - (float64(j) - t)
+	z0 := z - (float64(k) - t)
+	w0 := w - (float64(l) - t)
+
+	// Rank the coordinates to find which of the 24 simplices we're in
+	var rankX, rankY, rankZ, rankW int
+	if x0 > y0 {
+		rankX++
+	} else {
+		rankY++
+	}
+	if x0 > z0 {
+		rankX++
+	} else {
+		rankZ++
+	}
+	if x0 > w0 {
+		rankX++
+	} else {
+		rankW++
+	}
+	if y0 > z0 {
+		rankY++
+	} else {
+		rankZ++
+	}
+	if y0 > w0 {
+		rankY++
+	} else {
+		rankW++
+	}
+	if z0 > w0 {
+		rankZ++
+	} else {
+		rankW++
 	}
-	generationTime := time.Since(startGeneration)
-	fmt.Printf("  ├─ Generación del mapa base: %.3f s (%.1f millones de eval./s)\n",
-		generationTime.Seconds(), float64(totalEvals)/(generationTime.Seconds()*1000000))
 
-	// Aplicar función de suavizado
-	startSmoothing := time.Now()
-	for y := range mapSize {
-		for x := range mapSize {
-			heightmap[y][x] = smoothingFunction(heightmap[y][x])
+	step := func(rank, threshold int) int {
+		if rank >= threshold {
+			return 1
 		}
+		return 0
 	}
-	fmt.Printf("  ├─ Aplicación de filtro de suavizado: %.3f ms\n",
-		float64(time.Since(startSmoothing).Microseconds())/1000)
+	i1, j1, k1, l1 := step(rankX, 3), step(rankY, 3), step(rankZ, 3), step(rankW, 3)
+	i2, j2, k2, l2 := step(rankX, 2), step(rankY, 2), step(rankZ, 2), step(rankW, 2)
+	i3, j3, k3, l3 := step(rankX, 1), step(rankY, 1), step(rankZ, 1), step(rankW, 1)
+
+	// Offsets for the remaining corners
+	x1 := x0 - float64(i1) + G4
+	y1 := y0 - float64(j1) + G4
+	z1 := z0 - float64(k1) + G4
+	w1 := w0 - float64(l1) + G4
+	x2 := x0 - float64(i2) + 2.0*G4
+	y2 := y0 - float64(j2) + 2.0*G4
+	z2 := z0 - float64(k2) + 2.0*G4
+	w2 := w0 - float64(l2) + 2.0*G4
+	x3 := x0 - float64(i3) + 3.0*G4
+	y3 := y0 - float64(j3) + 3.0*G4
+	z3 := z0 - float64(k3) + 3.0*G4
+	w3 := w0 - float64(l3) + 3.0*G4
+	x4 := x0 - 1.0 + 4.0*G4
+	y4 := y0 - 1.0 + 4.0*G4
+	z4 := z0 - 1.0 + 4.0*G4
+	w4 := w0 - 1.0 + 4.0*G4
+
+	ii := i & 255
+	jj := j & 255
+	kk := k & 255
+	ll := l & 255
 
-	fmt.Printf("  └─ Tiempo total generación de mapa: %.3f s\n", time.Since(startTotal).Seconds())
+	// Calculate gradients from permutation table
+	gi0 := os.perm[ii+os.perm[jj+os.perm[kk+os.perm[ll]]]] % 32
+	gi1 := os.perm[ii+i1+os.perm[jj+j1+os.perm[kk+k1+os.perm[ll+l1]]]] % 32
+	gi2 := os.perm[ii+i2+os.perm[jj+j2+os.perm[kk+k2+os.perm[ll+l2]]]] % 32
+	gi3 := os.perm[ii+i3+os.perm[jj+j3+os.perm[kk+k3+os.perm[ll+l3]]]] % 32
+	gi4 := os.perm[ii+1+os.perm[jj+1+os.perm[kk+1+os.perm[ll+1]]]] % 32
+
+	corner := func(gi int, dx, dy, dz, dw float64) float64 {
+		t := 0.6 - dx*dx - dy*dy - dz*dz - dw*dw
+		if t < 0 {
+			return 0
+		}
+		t *= t
+		g := gradients4[gi]
+		return t * t * (g[0]*dx + g[1]*dy + g[2]*dz + g[3]*dw)
+	}
 
-	return heightmap
+	return 27.0 * (corner(gi0, x0, y0, z0, w0) + corner(gi1, x1, y1, z1, w1) +
+		corner(gi2, x2, y2, z2, w2) + corner(gi3, x3, y3, z3, w3) +
+		corner(gi4, x4, y4, z4, w4))
 }
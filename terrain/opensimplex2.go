@@ -0,0 +1,156 @@
+package terrain
+
+import (
+	"math"
+)
+
+// OpenSimplex2 constants, following K.jpg's reference OpenSimplex2 (fast variant).
+const (
+	os2PrimeX         int64 = 0x5205402B9270C86F
+	os2PrimeY         int64 = 0x598CD327003817B5
+	os2HashMultiplier int64 = 0x53A3F72DEEC546F5
+	os2Skew2D               = 0.366025403784439
+	os2Unskew2D             = -0.21132486540518713
+	os2GradsExponent        = 7
+	os2NumGrads             = 1 << os2GradsExponent
+	os2Normalizer2D         = 0.01001634121365712
+	os2RSquared2D           = 0.5
+)
+
+// os2Gradients holds 24 unit gradients (every 15°, offset 7.5°) pre-divided by the normalizer.
+var os2Gradients = func() [os2NumGrads * 2]float64 {
+	var g [os2NumGrads * 2]float64
+	for i := 0; i < os2NumGrads; i++ {
+		angle := (7.5 + 15*float64(i%24)) * math.Pi / 180
+		g[i*2] = math.Cos(angle) / os2Normalizer2D
+		g[i*2+1] = math.Sin(angle) / os2Normalizer2D
+	}
+	return g
+}()
+
+// OpenSimplex2 represents a true OpenSimplex2 noise generator with hashed gradients.
+type OpenSimplex2 struct {
+	seed int64
+}
+
+// NewOpenSimplex2 initializes a new OpenSimplex2 noise generator with a given seed.
+func NewOpenSimplex2(seed int64) *OpenSimplex2 {
+	return &OpenSimplex2{seed: seed}
+}
+
+// Seed returns the seed the generator was created with.
+func (o *OpenSimplex2) Seed() int64 {
+	return o.seed
+}
+
+// Eval2 computes the 2D OpenSimplex2 noise value at the given coordinates.
+func (o *OpenSimplex2) Eval2(x, y float64) float64 {
+	// Skew input to the triangular lattice
+	s := os2Skew2D * (x + y)
+	xs := x + s
+	ys := y + s
+
+	xsb := int64(math.Floor(xs))
+	ysb := int64(math.Floor(ys))
+	xi := xs - float64(xsb)
+	yi := ys - float64(ysb)
+
+	xsbp := xsb * os2PrimeX
+	ysbp := ysb * os2PrimeY
+
+	// Unskew back to get the offset from the base vertex
+	t := (xi + yi) * os2Unskew2D
+	dx0 := xi + t
+	dy0 := yi + t
+
+	value := 0.0
+	a0 := os2RSquared2D - dx0*dx0 - dy0*dy0
+	if a0 > 0 {
+		value = (a0 * a0) * (a0 * a0) * o.grad(xsbp, ysbp, dx0, dy0)
+	}
+
+	// Opposite vertex (1, 1)
+	dx1 := dx0 - (1 + 2*os2Unskew2D)
+	dy1 := dy0 - (1 + 2*os2Unskew2D)
+	a1 := os2RSquared2D - dx1*dx1 - dy1*dy1
+	if a1 > 0 {
+		value += (a1 * a1) * (a1 * a1) * o.grad(xsbp+os2PrimeX, ysbp+os2PrimeY, dx1, dy1)
+	}
+
+	// Third vertex depends on which triangle we're in
+	if dy0 > dx0 {
+		dx2 := dx0 - os2Unskew2D
+		dy2 := dy0 - (os2Unskew2D + 1)
+		a2 := os2RSquared2D - dx2*dx2 - dy2*dy2
+		if a2 > 0 {
+			value += (a2 * a2) * (a2 * a2) * o.grad(xsbp, ysbp+os2PrimeY, dx2, dy2)
+		}
+	} else {
+		dx2 := dx0 - (os2Unskew2D + 1)
+		dy2 := dy0 - os2Unskew2D
+		a2 := os2RSquared2D - dx2*dx2 - dy2*dy2
+		if a2 > 0 {
+			value += (a2 * a2) * (a2 * a2) * o.grad(xsbp+os2PrimeX, ysbp, dx2, dy2)
+		}
+	}
+
+	return value
+}
+
+// Eval2WithGradient computes the 2D OpenSimplex2 value together with its analytical
+// partial derivatives with respect to x and y.
+func (o *OpenSimplex2) Eval2WithGradient(x, y float64) (value, dx, dy float64) {
+	s := os2Skew2D * (x + y)
+	xs := x + s
+	ys := y + s
+
+	xsb := int64(math.Floor(xs))
+	ysb := int64(math.Floor(ys))
+	xi := xs - float64(xsb)
+	yi := ys - float64(ysb)
+	xsbp := xsb * os2PrimeX
+	ysbp := ysb * os2PrimeY
+
+	t := (xi + yi) * os2Unskew2D
+	dx0 := xi + t
+	dy0 := yi + t
+
+	// Each vertex contributes a^4 (g·d); its derivative is a^4 g - 8 a^3 (g·d) d
+	vertex := func(xsvp, ysvp int64, cx, cy float64) {
+		a := os2RSquared2D - cx*cx - cy*cy
+		if a <= 0 {
+			return
+		}
+		gx, gy := o.gradient(xsvp, ysvp)
+		dot := gx*cx + gy*cy
+		a2 := a * a
+		a3 := a2 * a
+		a4 := a2 * a2
+		value += a4 * dot
+		dx += a4*gx - 8*a3*dot*cx
+		dy += a4*gy - 8*a3*dot*cy
+	}
+	vertex(xsbp, ysbp, dx0, dy0)
+	vertex(xsbp+os2PrimeX, ysbp+os2PrimeY, dx0-(1+2*os2Unskew2D), dy0-(1+2*os2Unskew2D))
+	if dy0 > dx0 {
+		vertex(xsbp, ysbp+os2PrimeY, dx0-os2Unskew2D, dy0-(os2Unskew2D+1))
+	} else {
+		vertex(xsbp+os2PrimeX, ysbp, dx0-(os2Unskew2D+1), dy0-os2Unskew2D)
+	}
+	return value, dx, dy
+}
+
+// grad hashes a lattice vertex and returns its gradient dotted with the offset.
+func (o *OpenSimplex2) grad(xsvp, ysvp int64, dx, dy float64) float64 {
+	gx, gy := o.gradient(xsvp, ysvp)
+	return gx*dx + gy*dy
+}
+
+// gradient returns the hashed gradient vector of a lattice vertex.
+func (o *OpenSimplex2) gradient(xsvp, ysvp int64) (float64, float64) {
+	hash := o.seed ^ xsvp ^ ysvp
+	hash *= os2HashMultiplier
+	hash ^= hash >> (64 - os2GradsExponent + 1)
+	gi := int(hash) & ((os2NumGrads - 1) << 1)
+	return os2Gradients[gi], os2Gradients[gi+1]
+}
@@ -0,0 +1,121 @@
+package terrain
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// erosionTile is a block of the map whose droplets are simulated by one goroutine
+type erosionTile struct {
+	x0, y0, x1, y1 int   // Cells where the tile's droplets start
+	droplets       int   // Number of droplets starting in the tile
+	seed           int64 // Seed of the tile's own random source
+	stats          erosionStats
+}
+
+// applyErosionParallel simulates the droplets in square tiles processed in four
+// checkerboard phases. Tiles of the same phase are a whole tile apart and their
+// droplets stop once they leave the tile plus a margin smaller than half a tile, so
+// concurrently running tiles never read or write the same cells. Each tile has its
+// own droplet count and random source, so the result depends only on the seed and
+// tile size, never on the goroutine schedule.
+func applyErosionParallel(result *Heightmap, numDroplets int, params ErosionParams, brush *erosionBrush, rng *rand.Rand) erosionStats {
+	width := result.Width
+	height := result.Height
+
+	tileSize := params.TileSize
+	if tileSize <= 0 {
+		tileSize = 128
+	}
+	// Bilinear writes reach one cell past the droplet, the brush its radius
+	reach := 1
+	if brush != nil {
+		reach = max(brush.reach, 1)
+	}
+	tileSize = max(tileSize, max(8, 4*reach))
+
+	// Keep the writes of tiles one tile apart disjoint, with a cell of slack as well
+	margin := max(tileSize/2-1-reach, 0)
+
+	// Split the map into tiles, giving each a share of droplets proportional to its area
+	tilesX := (width + tileSize - 1) / tileSize
+	tilesY := (height + tileSize - 1) / tileSize
+	tiles := make([]erosionTile, 0, tilesX*tilesY)
+	totalArea := float64(width * height)
+	coveredArea := 0
+	assigned := 0
+	for ty := 0; ty < tilesY; ty++ {
+		for tx := 0; tx < tilesX; tx++ {
+			t := erosionTile{
+				x0:   tx * tileSize,
+				y0:   ty * tileSize,
+				x1:   min((tx+1)*tileSize, width),
+				y1:   min((ty+1)*tileSize, height),
+				seed: rng.Int63(),
+			}
+			coveredArea += (t.x1 - t.x0) * (t.y1 - t.y0)
+			target := int(float64(numDroplets) * float64(coveredArea) / totalArea)
+			t.droplets = target - assigned
+			assigned = target
+			tiles = append(tiles, t)
+		}
+	}
+	fmt.Printf("  ├─ Erosión en paralelo: %d trabajadores, %d bloques de %d celdas (margen %d)\n",
+		params.Workers, len(tiles), tileSize, margin)
+
+	startPhases := time.Now()
+	for phase := 0; phase < 4; phase++ {
+		jobs := make(chan *erosionTile, len(tiles))
+		for i := range tiles {
+			tx := (tiles[i].x0 / tileSize) % 2
+			ty := (tiles[i].y0 / tileSize) % 2
+			if ty*2+tx == phase {
+				jobs <- &tiles[i]
+			}
+		}
+		close(jobs)
+
+		var wg sync.WaitGroup
+		for w := 0; w < params.Workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for t := range jobs {
+					erodeTile(result, t, params, brush, margin)
+				}
+			}()
+		}
+		wg.Wait()
+
+		fmt.Printf("  │  ├─ Fase %d/4 completada - %.3f s\n", phase+1, time.Since(startPhases).Seconds())
+	}
+
+	// Merge in tile order so floating-point totals are reproducible
+	var stats erosionStats
+	for _, t := range tiles {
+		stats.merge(t.stats)
+	}
+	return stats
+}
+
+// erodeTile runs every droplet of a tile, confining them to the tile plus margin
+func erodeTile(result *Heightmap, t *erosionTile, params ErosionParams, brush *erosionBrush, margin int) {
+	r := rand.New(rand.NewSource(t.seed))
+	region := dropletRegion{
+		x0: float64(max(t.x0-margin, 0)),
+		y0: float64(max(t.y0-margin, 0)),
+		x1: float64(min(t.x1+margin, result.Width)),
+		y1: float64(min(t.y1+margin, result.Height)),
+	}
+
+	// Start positions stay inside [0, size-1) like the sequential mode
+	spanX := float64(min(t.x1, result.Width-1) - t.x0)
+	spanY := float64(min(t.y1, result.Height-1) - t.y0)
+	for d := 0; d < t.droplets; d++ {
+		x := float64(t.x0) + r.Float64()*spanX
+		y := float64(t.y0) + r.Float64()*spanY
+		simulateDroplet(result, x, y, params, region, brush, &t.stats)
+	}
+}
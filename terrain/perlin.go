@@ -0,0 +1,92 @@
+package terrain
+
+import (
+	"math"
+)
+
+// Perlin represents a classic (improved) Perlin gradient noise generator.
+type Perlin struct {
+	seed int64
+	perm [512]int
+}
+
+// NewPerlin initializes a new Perlin noise generator with a given seed.
+func NewPerlin(seed int64) *Perlin {
+	return &Perlin{seed: seed, perm: newPermutation(seed)}
+}
+
+// Seed returns the seed the generator was created with.
+func (p *Perlin) Seed() int64 {
+	return p.seed
+}
+
+// Eval2 computes the 2D Perlin noise value at the given coordinates.
+func (p *Perlin) Eval2(x, y float64) float64 {
+	xf := math.Floor(x)
+	yf := math.Floor(y)
+	i := int(xf) & 255
+	j := int(yf) & 255
+
+	// Position inside the lattice cell and its smoothed weights
+	dx := x - xf
+	dy := y - yf
+	u := fade(dx)
+	v := fade(dy)
+
+	// Hash the four cell corners
+	aa := p.perm[p.perm[i]+j]
+	ab := p.perm[p.perm[i]+j+1]
+	ba := p.perm[p.perm[i+1]+j]
+	bb := p.perm[p.perm[i+1]+j+1]
+
+	n0 := lerp(p.grad(aa, dx, dy), p.grad(ba, dx-1, dy), u)
+	n1 := lerp(p.grad(ab, dx, dy-1), p.grad(bb, dx-1, dy-1), u)
+
+	// Unit gradients peak at sqrt(2)/2, rescale to [-1, 1]
+	return math.Sqrt2 * lerp(n0, n1, v)
+}
+
+// Eval2WithGradient computes the 2D Perlin value together with its analytical
+// partial derivatives with respect to x and y.
+func (p *Perlin) Eval2WithGradient(x, y float64) (value, dx, dy float64) {
+	xf := math.Floor(x)
+	yf := math.Floor(y)
+	i := int(xf) & 255
+	j := int(yf) & 255
+
+	fx := x - xf
+	fy := y - yf
+	u := fade(fx)
+	v := fade(fy)
+	du := fadeDerivative(fx)
+	dv := fadeDerivative(fy)
+
+	g00 := gradients[p.perm[p.perm[i]+j]&7]
+	g10 := gradients[p.perm[p.perm[i+1]+j]&7]
+	g01 := gradients[p.perm[p.perm[i]+j+1]&7]
+	g11 := gradients[p.perm[p.perm[i+1]+j+1]&7]
+
+	n00 := g00[0]*fx + g00[1]*fy
+	n10 := g10[0]*(fx-1) + g10[1]*fy
+	n01 := g01[0]*fx + g01[1]*(fy-1)
+	n11 := g11[0]*(fx-1) + g11[1]*(fy-1)
+
+	// Bilinear blend of the corner ramps and its derivative (product rule)
+	k1 := n10 - n00
+	k2 := n01 - n00
+	k3 := n00 - n10 - n01 + n11
+	value = n00 + k1*u + k2*v + k3*u*v
+
+	gx := g00[0] + (g10[0]-g00[0])*u + (g01[0]-g00[0])*v + (g00[0]-g10[0]-g01[0]+g11[0])*u*v
+	gy := g00[1] + (g10[1]-g00[1])*u + (g01[1]-g00[1])*v + (g00[1]-g10[1]-g01[1]+g11[1])*u*v
+	dx = gx + (k1+k3*v)*du
+	dy = gy + (k2+k3*u)*dv
+
+	return math.Sqrt2 * value, math.Sqrt2 * dx, math.Sqrt2 * dy
+}
+
+// grad returns the dot product between a hashed corner gradient and the offset vector.
+func (p *Perlin) grad(hash int, x, y float64) float64 {
+	g := gradients[hash&7]
+	return g[0]*x + g[1]*y
+}
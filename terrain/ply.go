@@ -0,0 +1,156 @@
+package terrain
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// PLYFormat selecciona la codificación del cuerpo del archivo PLY
+type PLYFormat int
+
+const (
+	PLYBinary PLYFormat = iota // binary_little_endian 1.0: posiciones float32, compacto y rápido de escribir
+	PLYASCII                   // ascii 1.0: texto legible con la representación float32 más corta
+)
+
+// String devuelve el nombre del formato que se escribe en la cabecera PLY
+func (f PLYFormat) String() string {
+	switch f {
+	case PLYBinary:
+		return "binary_little_endian"
+	case PLYASCII:
+		return "ascii"
+	default:
+		return fmt.Sprintf("PLYFormat(%d)", int(f))
+	}
+}
+
+// PLYWriter escribe una malla de triángulos con color en un archivo PLY sin guardarla en
+// memoria: primero la cabecera con los totales declarados, después todos los vértices y por
+// último todas las caras. Las propiedades son float x, y, z, uchar red, green, blue y una
+// lista uchar/int de índices de vértices, que cargan fauxgl y los visores habituales.
+type PLYWriter struct {
+	writer   *bufio.Writer
+	format   PLYFormat
+	vertices int // Totales declarados en la cabecera
+	faces    int
+	vertex   int // Elementos escritos hasta ahora
+	face     int
+	buf      []byte
+}
+
+// NewPLYWriter escribe la cabecera PLY en w y devuelve el escritor para los vértices y caras
+func NewPLYWriter(w io.Writer, format PLYFormat, vertices, faces int) (*PLYWriter, error) {
+	if format != PLYBinary && format != PLYASCII {
+		return nil, fmt.Errorf("formato PLY no soportado: %v", format)
+	}
+	p := &PLYWriter{
+		writer:   bufio.NewWriterSize(w, 1<<16),
+		format:   format,
+		vertices: vertices,
+		faces:    faces,
+		buf:      make([]byte, 0, 64),
+	}
+
+	fmt.Fprintf(p.writer, "ply\n")
+	fmt.Fprintf(p.writer, "format %s 1.0\n", format)
+	fmt.Fprintf(p.writer, "element vertex %d\n", vertices)
+	fmt.Fprintf(p.writer, "property float x\n")
+	fmt.Fprintf(p.writer, "property float y\n")
+	fmt.Fprintf(p.writer, "property float z\n")
+	fmt.Fprintf(p.writer, "property uchar red\n")
+	fmt.Fprintf(p.writer, "property uchar green\n")
+	fmt.Fprintf(p.writer, "property uchar blue\n")
+	fmt.Fprintf(p.writer, "element face %d\n", faces)
+	fmt.Fprintf(p.writer, "property list uchar int vertex_indices\n")
+	if _, err := fmt.Fprintf(p.writer, "end_header\n"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// WriteVertex escribe la posición y el color (componentes de 0 a 1) del siguiente vértice
+func (p *PLYWriter) WriteVertex(v [3]float64, c [3]float64) error {
+	if p.vertex >= p.vertices {
+		return fmt.Errorf("se han declarado %d vértices en la cabecera PLY", p.vertices)
+	}
+	p.vertex++
+
+	b := p.buf[:0]
+	switch p.format {
+	case PLYBinary:
+		for _, f := range v {
+			b = binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(f)))
+		}
+		for _, f := range c {
+			b = append(b, colorByte(f))
+		}
+	case PLYASCII:
+		for _, f := range v {
+			b = strconv.AppendFloat(b, float64(float32(f)), 'g', -1, 32)
+			b = append(b, ' ')
+		}
+		for k, f := range c {
+			b = strconv.AppendUint(b, uint64(colorByte(f)), 10)
+			if k < 2 {
+				b = append(b, ' ')
+			}
+		}
+		b = append(b, '\n')
+	}
+	p.buf = b
+	_, err := p.writer.Write(b)
+	return err
+}
+
+// WriteFace escribe el siguiente triángulo; todos los vértices deben haberse escrito antes
+func (p *PLYWriter) WriteFace(f [3]int) error {
+	if p.vertex != p.vertices {
+		return fmt.Errorf("faltan vértices antes de las caras: %d de %d", p.vertex, p.vertices)
+	}
+	if p.face >= p.faces {
+		return fmt.Errorf("se han declarado %d caras en la cabecera PLY", p.faces)
+	}
+	p.face++
+
+	b := p.buf[:0]
+	switch p.format {
+	case PLYBinary:
+		b = append(b, 3)
+		for _, i := range f {
+			b = binary.LittleEndian.AppendUint32(b, uint32(int32(i)))
+		}
+	case PLYASCII:
+		b = append(b, '3')
+		for _, i := range f {
+			b = append(b, ' ')
+			b = strconv.AppendInt(b, int64(i), 10)
+		}
+		b = append(b, '\n')
+	}
+	p.buf = b
+	_, err := p.writer.Write(b)
+	return err
+}
+
+// Close vacía el búfer y comprueba que se han escrito todos los elementos declarados.
+// No cierra el io.Writer subyacente.
+func (p *PLYWriter) Close() error {
+	if err := p.writer.Flush(); err != nil {
+		return err
+	}
+	if p.vertex != p.vertices || p.face != p.faces {
+		return fmt.Errorf("archivo PLY incompleto: %d de %d vértices y %d de %d caras",
+			p.vertex, p.vertices, p.face, p.faces)
+	}
+	return nil
+}
+
+// colorByte convierte una componente de color de 0 a 1 en un uchar de 0 a 255
+func colorByte(c float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(1, c)) * 255))
+}
@@ -0,0 +1,190 @@
+package terrain
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// RTIN es la red irregular de triángulos rectángulos de un heightmap (Evans et al., 2001;
+// como en Martini de Mapbox): la rejilla se divide recursivamente en triángulos rectángulos
+// y cada punto de división guarda el mayor error vertical de los triángulos que refinaría y
+// de todos sus descendientes. Así se extraen mallas para cualquier error máximo sin pasar
+// por la malla completa.
+type RTIN struct {
+	heightmap *Heightmap
+	size      int       // Lado de la rejilla, 2^k + 1, que cubre todo el heightmap
+	errors    []float64 // Error por vértice de la rejilla (y*size + x), en los puntos medios de las hipotenusas
+}
+
+// NewRTIN calcula los errores de todos los triángulos de la jerarquía. Los heightmaps cuyos
+// lados no miden 2^k + 1 se colocan en la siguiente rejilla de ese tamaño: los triángulos
+// que salen del mapa siempre se dividen y las celdas de fuera no forman parte de las mallas.
+func NewRTIN(heightmap *Heightmap) *RTIN {
+	startTotal := time.Now()
+	tileSize := 1
+	for tileSize < max(heightmap.Width, heightmap.Height)-1 {
+		tileSize *= 2
+	}
+	size := tileSize + 1
+	fmt.Printf("Iniciando cálculo de errores RTIN (heightmap %dx%d, rejilla %dx%d)...\n",
+		heightmap.Width, heightmap.Height, size, size)
+
+	t := &RTIN{heightmap: heightmap, size: size, errors: make([]float64, size*size)}
+	inside := func(x, y int) bool { return x < heightmap.Width && y < heightmap.Height }
+
+	// Los triángulos se numeran como un árbol binario (los hijos de i+2 son 2(i+2) y
+	// 2(i+2)+1), así que al recorrerlos hacia atrás cada hijo se visita antes que su padre
+	numTriangles := tileSize*tileSize*2 - 2
+	numParents := numTriangles - tileSize*tileSize
+	for i := numTriangles - 1; i >= 0; i-- {
+		ax, ay, bx, by := rtinTriangle(i, tileSize)
+		mx, my := (ax+bx)/2, (ay+by)/2
+		cx, cy := mx+my-ay, my+ax-mx
+		middle := my*size + mx
+
+		e := math.Inf(1)
+		if inside(ax, ay) && inside(bx, by) && inside(cx, cy) {
+			e = t.triangleError(ax, ay, bx, by, cx, cy)
+		}
+		if i < numParents {
+			left := ((ay+cy)/2)*size + (ax+cx)/2
+			right := ((by+cy)/2)*size + (bx+cx)/2
+			e = math.Max(e, math.Max(t.errors[left], t.errors[right]))
+		}
+		t.errors[middle] = math.Max(t.errors[middle], e)
+	}
+
+	fmt.Printf("  └─ Tiempo total de cálculo de errores RTIN: %.3f ms\n",
+		float64(time.Since(startTotal).Microseconds())/1000)
+	return t
+}
+
+// triangleError devuelve la mayor distancia vertical entre el heightmap y el plano que pasa
+// por los vértices del triángulo, en los puntos de la rejilla que cubre. Martini solo mide
+// el punto medio de la hipotenusa, lo que puede subestimar el error de triángulos grandes.
+func (t *RTIN) triangleError(ax, ay, bx, by, cx, cy int) float64 {
+	hm := t.heightmap
+	ha, hb, hc := hm.At(ax, ay), hm.At(bx, by), hm.At(cx, cy)
+	area := (bx-ax)*(cy-ay) - (by-ay)*(cx-ax) // El doble del área con signo
+	if area == 0 {
+		return 0
+	}
+	// Pesos baricéntricos (multiplicados por el área) a partir de las funciones de arista
+	edge := func(ux, uy, vx, vy, x, y int) int { return (vx-ux)*(y-uy) - (vy-uy)*(x-ux) }
+	minX, maxX := min(ax, min(bx, cx)), max(ax, max(bx, cx))
+	minY, maxY := min(ay, min(by, cy)), max(ay, max(by, cy))
+
+	worst := 0.0
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			wa := edge(bx, by, cx, cy, x, y)
+			wb := edge(cx, cy, ax, ay, x, y)
+			wc := area - wa - wb
+			if area < 0 {
+				wa, wb, wc = -wa, -wb, -wc
+			}
+			if wa < 0 || wb < 0 || wc < 0 {
+				continue
+			}
+			z := (float64(wa)*ha + float64(wb)*hb + float64(wc)*hc) / math.Abs(float64(area))
+			worst = math.Max(worst, math.Abs(z-hm.At(x, y)))
+		}
+	}
+	return worst
+}
+
+// rtinTriangle devuelve los extremos de la hipotenusa del triángulo i de la jerarquía
+func rtinTriangle(i, tileSize int) (ax, ay, bx, by int) {
+	id := i + 2
+	var cx, cy int
+	if id&1 != 0 {
+		bx, by, cx = tileSize, tileSize, tileSize // Mitad inferior izquierda de la rejilla
+	} else {
+		ax, ay, cy = tileSize, tileSize, tileSize // Mitad superior derecha
+	}
+	for id >>= 1; id > 1; id >>= 1 {
+		mx, my := (ax+bx)/2, (ay+by)/2
+		if id&1 != 0 {
+			bx, by = ax, ay
+			ax, ay = cx, cy
+		} else {
+			ax, ay = bx, by
+			bx, by = cx, cy
+		}
+		cx, cy = mx, my
+	}
+	return ax, ay, bx, by
+}
+
+// Mesh extrae la malla más simple de la red cuyo error vertical no supera maxError (en las
+// unidades del heightmap), con el mismo formato que GenerateHeightmapMesh
+func (t *RTIN) Mesh(maxError float64) ([][3]float64, [][3]int, [][3]float64) {
+	startTotal := time.Now()
+	heightmap := t.heightmap
+	size := t.size
+	fmt.Printf("Iniciando generación de malla adaptativa RTIN (error máximo: %.4f)...\n", maxError)
+
+	minHeight, maxHeight := heightmap.Range()
+	index := make([]int32, size*size)
+	for i := range index {
+		index[i] = -1
+	}
+	var vertices [][3]float64
+	var colors [][3]float64
+	var faces [][3]int
+	vertex := func(x, y int) int {
+		i := y*size + x
+		if index[i] < 0 {
+			index[i] = int32(len(vertices))
+			h := heightmap.At(x, y)
+			vertices = append(vertices, [3]float64{float64(x) * heightmap.CellSize, float64(y) * heightmap.CellSize, -h})
+			colors = append(colors, ColorFromHeight(h, minHeight, maxHeight))
+		}
+		return int(index[i])
+	}
+
+	var split func(ax, ay, bx, by, cx, cy int)
+	split = func(ax, ay, bx, by, cx, cy int) {
+		mx, my := (ax+bx)/2, (ay+by)/2
+		legX, legY := ax-cx, ay-cy // Las hojas tienen catetos de una celda
+		if legX*legX+legY*legY > 1 && t.errors[my*size+mx] > maxError {
+			split(cx, cy, ax, ay, mx, my)
+			split(bx, by, cx, cy, mx, my)
+			return
+		}
+		if max(max(ax, bx), cx) >= heightmap.Width || max(max(ay, by), cy) >= heightmap.Height {
+			return
+		}
+		// Mismo orden de vértices que GenerateHeightmapMesh: antihorario en (x, y)
+		a, b, c := vertex(ax, ay), vertex(bx, by), vertex(cx, cy)
+		if (bx-ax)*(cy-ay)-(by-ay)*(cx-ax) < 0 {
+			b, c = c, b
+		}
+		faces = append(faces, [3]int{a, b, c})
+	}
+	tileSize := size - 1
+	split(0, 0, tileSize, tileSize, tileSize, 0)
+	split(tileSize, tileSize, 0, 0, 0, tileSize)
+
+	full := 2 * max(heightmap.Width-1, 0) * max(heightmap.Height-1, 0)
+	fmt.Printf("  ├─ %d vértices, %d triángulos (%.1f%% de la malla completa)\n",
+		len(vertices), len(faces), float64(len(faces))/float64(max(full, 1))*100)
+	fmt.Printf("  └─ Tiempo total generación de malla adaptativa: %.3f ms\n",
+		float64(time.Since(startTotal).Microseconds())/1000)
+
+	return vertices, faces, colors
+}
+
+// GenerateAdaptiveLODs extrae del heightmap una malla RTIN por cada error vertical máximo,
+// calculando los errores una sola vez. A diferencia de GenerateLODs, el borde de cada nivel
+// también se simplifica.
+func GenerateAdaptiveLODs(heightmap *Heightmap, maxErrors []float64) []MeshLOD {
+	t := NewRTIN(heightmap)
+	lods := make([]MeshLOD, len(maxErrors))
+	for k, maxError := range maxErrors {
+		vertices, faces, colors := t.Mesh(maxError)
+		lods[k] = MeshLOD{Vertices: vertices, Faces: faces, Colors: colors, Error: maxError}
+	}
+	return lods
+}
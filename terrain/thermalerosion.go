@@ -0,0 +1,119 @@
+package terrain
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Parameters for thermal erosion (talus slope relaxation)
+type ThermalParams struct {
+	Iterations   int     // Number of relaxation passes over the whole map
+	TalusAngle   float64 // Steepest stable slope, in degrees
+	TransferRate float64 // Fraction of the excess material moved per pass (0-1)
+	Neighbours   int     // 4 (von Neumann) or 8 (Moore) neighbourhood
+	CellSize     float64 // Horizontal distance between samples in height units (0 uses the heightmap's)
+}
+
+// thermalOffsets lists the neighbour offsets; the first four are the 4-neighbourhood
+var thermalOffsets = [8][2]int{
+	{1, 0}, {-1, 0}, {0, 1}, {0, -1},
+	{1, 1}, {-1, 1}, {1, -1}, {-1, -1},
+}
+
+// ApplyThermalErosion moves material downhill wherever the slope between neighbouring
+// cells exceeds the talus angle, producing scree slopes at the foot of steep walls.
+// Each pass computes all transfers from the same snapshot, so results do not depend
+// on the order in which cells are visited.
+func ApplyThermalErosion(heightmap *Heightmap, params ThermalParams) *Heightmap {
+	startTotal := time.Now()
+	fmt.Printf("Iniciando erosión térmica (%d iteraciones, talud: %.1f°)...\n",
+		params.Iterations, params.TalusAngle)
+
+	result := heightmap.Copy()
+	width := result.Width
+	height := result.Height
+
+	cellSize := params.CellSize
+	if cellSize <= 0 {
+		cellSize = heightmap.CellSize
+	}
+	neighbours := 8
+	if params.Neighbours == 4 {
+		neighbours = 4
+	}
+	fmt.Printf("  ├─ Vecindad: %d celdas, tamaño de celda: %.3f\n", neighbours, cellSize)
+
+	// Maximum stable height difference towards each neighbour
+	talus := math.Tan(params.TalusAngle * math.Pi / 180)
+	var maxDiff [8]float64
+	for k := 0; k < neighbours; k++ {
+		o := thermalOffsets[k]
+		maxDiff[k] = talus * cellSize * math.Hypot(float64(o[0]), float64(o[1]))
+	}
+
+	delta := make([]float64, len(result.Data))
+	totalMoved := 0.0
+	unstableCells := 0
+
+	startIterations := time.Now()
+	reportInterval := max(params.Iterations/10, 1)
+	for it := 0; it < params.Iterations; it++ {
+		if it > 0 && it%reportInterval == 0 {
+			fmt.Printf("  │  ├─ %.1f%% completado - %.3f s\n",
+				float64(it)/float64(params.Iterations)*100, time.Since(startIterations).Seconds())
+		}
+
+		for i := range delta {
+			delta[i] = 0
+		}
+
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				h := result.Data[y*width+x]
+
+				// Find how far each lower neighbour exceeds the stable slope
+				var excess [8]float64
+				maxExcess, totalExcess := 0.0, 0.0
+				for k := 0; k < neighbours; k++ {
+					nx, ny := x+thermalOffsets[k][0], y+thermalOffsets[k][1]
+					if nx < 0 || nx >= width || ny < 0 || ny >= height {
+						continue
+					}
+					e := h - result.Data[ny*width+nx] - maxDiff[k]
+					if e > 0 {
+						excess[k] = e
+						totalExcess += e
+						maxExcess = math.Max(maxExcess, e)
+					}
+				}
+				if totalExcess == 0 {
+					continue
+				}
+
+				// Move half the largest excess (which levels that pair), shared by excess
+				moved := params.TransferRate * maxExcess / 2
+				delta[y*width+x] -= moved
+				for k := 0; k < neighbours; k++ {
+					if excess[k] > 0 {
+						nx, ny := x+thermalOffsets[k][0], y+thermalOffsets[k][1]
+						delta[ny*width+nx] += moved * excess[k] / totalExcess
+					}
+				}
+				totalMoved += moved
+				unstableCells++
+			}
+		}
+
+		for i, d := range delta {
+			result.Data[i] += d
+		}
+	}
+
+	fmt.Printf("  ├─ Estadísticas:\n")
+	fmt.Printf("  │  ├─ Celdas inestables (suma de iteraciones): %d\n", unstableCells)
+	fmt.Printf("  │  └─ Material desplazado: %.1f unidades\n", totalMoved)
+	fmt.Printf("  └─ Tiempo total de erosión térmica: %.3f s\n", time.Since(startTotal).Seconds())
+
+	return result
+}
@@ -0,0 +1,45 @@
+package terrain
+
+import (
+	"math"
+)
+
+// ValueNoise represents a lattice noise generator that interpolates random values.
+type ValueNoise struct {
+	seed int64
+	perm [512]int
+}
+
+// NewValueNoise initializes a new value noise generator with a given seed.
+func NewValueNoise(seed int64) *ValueNoise {
+	return &ValueNoise{seed: seed, perm: newPermutation(seed)}
+}
+
+// Seed returns the seed the generator was created with.
+func (vn *ValueNoise) Seed() int64 {
+	return vn.seed
+}
+
+// Eval2 computes the 2D value noise at the given coordinates.
+func (vn *ValueNoise) Eval2(x, y float64) float64 {
+	xf := math.Floor(x)
+	yf := math.Floor(y)
+	i := int(xf) & 255
+	j := int(yf) & 255
+
+	u := fade(x - xf)
+	v := fade(y - yf)
+
+	// Random values at the four cell corners
+	v00 := vn.value(vn.perm[vn.perm[i]+j])
+	v10 := vn.value(vn.perm[vn.perm[i+1]+j])
+	v01 := vn.value(vn.perm[vn.perm[i]+j+1])
+	v11 := vn.value(vn.perm[vn.perm[i+1]+j+1])
+
+	return lerp(lerp(v00, v10, u), lerp(v01, v11, u), v)
+}
+
+// value maps a permutation entry to a lattice value in [-1, 1].
+func (vn *ValueNoise) value(hash int) float64 {
+	return float64(hash)/127.5 - 1
+}
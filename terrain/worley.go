@@ -0,0 +1,69 @@
+package terrain
+
+import (
+	"math"
+)
+
+// WorleyMode selects which feature-point distance Worley noise returns.
+type WorleyMode int
+
+const (
+	WorleyF1        WorleyMode = iota // Distance to the nearest feature point
+	WorleyF2                          // Distance to the second nearest feature point
+	WorleyF2MinusF1                   // Cell borders (ridges between cells)
+)
+
+// Worley represents a cellular noise generator with one jittered feature point per cell.
+type Worley struct {
+	seed   int64
+	Mode   WorleyMode
+	Jitter float64 // How far feature points may move from the cell centre (0-1)
+}
+
+// NewWorley initializes a new Worley (F1, fully jittered) noise generator with a given seed.
+func NewWorley(seed int64) *Worley {
+	return &Worley{seed: seed, Mode: WorleyF1, Jitter: 1.0}
+}
+
+// Seed returns the seed the generator was created with.
+func (w *Worley) Seed() int64 {
+	return w.seed
+}
+
+// Eval2 computes the 2D cellular noise value at the given coordinates.
+func (w *Worley) Eval2(x, y float64) float64 {
+	ci := int(math.Floor(x))
+	cj := int(math.Floor(y))
+
+	// Track the two closest feature points in the 3x3 cell neighbourhood
+	f1, f2 := math.Inf(1), math.Inf(1)
+	for dj := -1; dj <= 1; dj++ {
+		for di := -1; di <= 1; di++ {
+			h := hash2(w.seed, ci+di, cj+dj)
+			jx := float64(h&0xFFFF) / 0xFFFF
+			jy := float64((h>>16)&0xFFFF) / 0xFFFF
+			px := float64(ci+di) + 0.5 + (jx-0.5)*w.Jitter
+			py := float64(cj+dj) + 0.5 + (jy-0.5)*w.Jitter
+
+			d := math.Hypot(px-x, py-y)
+			if d < f1 {
+				f1, f2 = d, f1
+			} else if d < f2 {
+				f2 = d
+			}
+		}
+	}
+
+	var d float64
+	switch w.Mode {
+	case WorleyF2:
+		d = f2
+	case WorleyF2MinusF1:
+		d = f2 - f1
+	default:
+		d = f1
+	}
+
+	// Distances lie roughly in [0, 1], map them to [-1, 1]
+	return math.Max(-1, math.Min(1, d*2-1))
+}